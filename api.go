@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
@@ -13,6 +15,12 @@ import (
 	"time"
 
 	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/bradberger/go-memberclicks/internal/httplog"
+	"github.com/bradberger/go-memberclicks/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Scopes
@@ -29,11 +37,65 @@ var (
 type API struct {
 	orgID, clientID, clientSecret, accessToken string
 
+	// token is the token accessToken came from, and renewGrant the grant type used to renew it.
+	token      *Token
+	renewGrant string
+	renewMu    sync.Mutex
+
 	Client  *http.Client
 	Timeout time.Duration
 
-	lastResponse *http.Response
-	lastRequest  *http.Request
+	// Transport sends the requests if there's no Client. If nil, DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// UserAgent, if set, is the User-Agent header of requests
+	UserAgent string
+
+	// Scopes are the scopes Auth requests. If empty, ScopeRead is used.
+	Scopes []string
+
+	// BaseURL is the URL requests are sent to, for example a proxy or a test server.
+	// If empty, the organization's own https://{orgID}.memberclicks.net is used.
+	BaseURL string
+
+	// RetryPolicy decides if failed requests are sent again. If nil, requests are not retried.
+	RetryPolicy RetryPolicy
+
+	// Limiter, if set, is waited on before each request is sent.
+	Limiter Limiter
+
+	// CircuitBreaker, if set, fails requests with ErrCircuitOpen while the servers are down.
+	CircuitBreaker *CircuitBreaker
+
+	// TokenSource, if set, provides the access tokens for requests instead of Auth or SetToken.
+	TokenSource oauth2.TokenSource
+
+	// Logger, if set, logs every request. Headers and bodies are logged at debug level,
+	// with credentials, tokens and the RedactAttributes profile attributes redacted.
+	Logger           *slog.Logger
+	RedactAttributes []string
+
+	// TracerProvider creates the spans for requests. If nil, the global provider is used.
+	TracerProvider trace.TracerProvider
+
+	// Metrics, if set, collects metrics about the requests, for example a prommetrics.Metrics.
+	Metrics Metrics
+
+	// Cache, if set, caches the reference data: countries, events, groups, member statuses
+	// and member types. CacheTTL is how long responses are fresh per endpoint path, and
+	// CacheStale how long expired responses are still returned while they're refreshed.
+	Cache      Cache
+	CacheTTL   map[string]time.Duration
+	CacheStale time.Duration
+	cacheGroup singleflight.Group
+
+	// Dedupe makes identical GET requests with the same credential which are in flight
+	// at the same time share one request. Each caller gets its own copy of the result,
+	// the API error and the response metadata. Middlewares only see the request which is sent.
+	Dedupe      bool
+	dedupeGroup singleflight.Group
+
+	middleware []Middleware
 
 	sync.RWMutex
 }
@@ -47,25 +109,26 @@ func (a *API) getTimeout() time.Duration {
 
 // GetAuthCodeURL returns a auth code URL for redirecting the client to authorize with MemberClicks
 func (a *API) GetAuthCodeURL(scope, state, redirectURL string) string {
-	return fmt.Sprintf(
-		"https://%s.memberclicks.net/oauth/v1/authorize?response_type=code&client_id=%s&scope=%s&state=%s&redirect_uri=%s",
-		a.orgID,
-		a.clientID,
-		scope,
-		state,
-		redirectURL,
-	)
+	return a.authorizeURL("code", scope, state, redirectURL, nil)
 }
 
 func (a *API) GetAuthRequestURL(scope, state, redirectURL string) string {
-	return fmt.Sprintf(
-		"https://%s.memberclicks.net/oauth/v1/authorize?response_type=token&client_id=%s&scope=%s&state=%s&redirect_uri=%s",
-		a.orgID,
-		a.clientID,
-		scope,
-		state,
-		redirectURL,
-	)
+	return a.authorizeURL("token", scope, state, redirectURL, nil)
+}
+
+// authorizeURL returns the URL of the authorize page with the escaped parameters
+func (a *API) authorizeURL(responseType, scope, state, redirectURL string, extra url.Values) string {
+	q := url.Values{
+		"response_type": {responseType},
+		"client_id":     {a.clientID},
+		"scope":         {scope},
+		"state":         {state},
+		"redirect_uri":  {redirectURL},
+	}
+	for k, v := range extra {
+		q[k] = v
+	}
+	return a.baseURL() + "/oauth/v1/authorize?" + q.Encode()
 }
 
 // AuthCodeRedirect does an http.Redirect to the authcodeurl
@@ -75,6 +138,11 @@ func (a *API) AuthCodeRedirect(w http.ResponseWriter, r *http.Request, scope, st
 
 // GetToken trades an auth code for an access token
 func (a *API) GetToken(ctx context.Context, authCode, scope, state, redirectURL string) (*Token, error) {
+	return a.exchangeCode(withOperation(ctx, "GetToken"), authCode, scope, state, redirectURL, nil)
+}
+
+// exchangeCode trades an auth code for an access token, with the extra form values
+func (a *API) exchangeCode(ctx context.Context, authCode, scope, state, redirectURL string, extra url.Values) (*Token, error) {
 	var t Token
 	form := url.Values{
 		"grant_type":   {"authorization_code"},
@@ -83,33 +151,41 @@ func (a *API) GetToken(ctx context.Context, authCode, scope, state, redirectURL
 		"state":        {state},
 		"redirect_uri": {redirectURL},
 	}
-	if err := a.Post(ctx, "/oauth/v1/token", form, &t); err != nil {
+	for k, v := range extra {
+		form[k] = v
+	}
+	if err := a.postToken(ctx, form, &t); err != nil {
 		return nil, err
 	}
 	return &t, nil
 }
 
 // SetAccessToken sets the internal access token to use on requests if no other Authorization header is set.
+// The access token is used as is and never renewed, use SetToken for that.
 func (a *API) SetAccessToken(accessToken string) *API {
-	a.accessToken = accessToken
+	a.Lock()
+	a.accessToken, a.token, a.renewGrant = accessToken, nil, ""
+	a.Unlock()
 	return a
 }
 
-// Auth initializes a default ClientCredentials requests and stores the resulting access token if successful
+// Auth initializes a default ClientCredentials requests and stores the resulting access token if successful.
+// The token is renewed with another ClientCredentials request before it expires.
 func (a *API) Auth(ctx context.Context) error {
-	t, err := a.ClientCredentials(ctx, "read")
+	t, err := a.ClientCredentials(ctx, a.scope())
 	if err != nil {
 		return err
 	}
-	a.accessToken = t.AccessToken
+	a.setToken(t, grantClientCredentials)
 	return nil
 }
 
 // ClientCredentials returns a client_credentials token
 func (a *API) ClientCredentials(ctx context.Context, scope string) (*Token, error) {
+	ctx = withOperation(ctx, "ClientCredentials")
 	var t Token
 	form := url.Values{"grant_type": {"client_credentials"}, "scope": {scope}}
-	if err := a.Post(ctx, "/oauth/v1/token", form, &t); err != nil {
+	if err := a.postToken(ctx, form, &t); err != nil {
 		return nil, err
 	}
 	return &t, nil
@@ -117,6 +193,7 @@ func (a *API) ClientCredentials(ctx context.Context, scope string) (*Token, erro
 
 // OwnerPassword returns a token with the owner "password" grant type
 func (a *API) OwnerPassword(ctx context.Context, username, password string) (*Token, error) {
+	ctx = withOperation(ctx, "OwnerPassword")
 	var t Token
 	form := url.Values{
 		"scope":      {"read"},
@@ -124,7 +201,7 @@ func (a *API) OwnerPassword(ctx context.Context, username, password string) (*To
 		"username":   {username},
 		"password":   {password},
 	}
-	if err := a.Post(ctx, "/oauth/v1/token", form, &t); err != nil {
+	if err := a.postToken(ctx, form, &t); err != nil {
 		return nil, err
 	}
 	return &t, nil
@@ -138,22 +215,25 @@ func (a *API) CheckPassword(ctx context.Context, username, password string) (err
 
 // RefreshToken gets a new token from a refresh token
 func (a *API) RefreshToken(ctx context.Context, scope string, refreshToken string) (*Token, error) {
+	// Public clients renew the tokens of GetTokenWithPKCE without a secret
+	ctx = context.WithValue(withOperation(ctx, "RefreshToken"), publicClientKey{}, true)
 	var t Token
 	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}
-	if err := a.Post(ctx, "/oauth/v1/token", form, &t); err != nil {
+	if err := a.postToken(ctx, form, &t); err != nil {
 		return nil, err
 	}
 	return &t, nil
 }
 
 func (a *API) ResourceOwnerCredentials(ctx context.Context, username, password string) (*Token, error) {
+	ctx = withOperation(ctx, "ResourceOwnerCredentials")
 	var t Token
 	form := url.Values{}
 	form.Add("grant_type", "password")
 	form.Add("scope", "read")
 	form.Add("username", username)
 	form.Add("password", password)
-	if err := a.Post(ctx, "/oauth/v1/token", form, &t); err != nil {
+	if err := a.postToken(ctx, form, &t); err != nil {
 		return nil, err
 	}
 	return &t, nil
@@ -161,107 +241,296 @@ func (a *API) ResourceOwnerCredentials(ctx context.Context, username, password s
 
 // Post sends a POST request to the urlStr and marshals the response into result
 func (a *API) Post(ctx context.Context, urlStr string, form url.Values, result interface{}) error {
-	req, err := http.NewRequest("POST", a.makeURL(urlStr), bytes.NewBufferString(form.Encode()))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	return a.Do(ctx, req, result)
+	return a.request(ctx, "POST", urlStr, nil, form, result, encodeForm)
 }
 
 // PostJSON sends a JSON POST request to the API with the JSON encoded data
 func (a *API) PostJSON(ctx context.Context, urlStr string, data, result interface{}) error {
+	return a.request(ctx, "POST", urlStr, nil, data, result, encodeJSON)
+}
 
-	b, err := json.Marshal(data)
-	if err != nil {
-		return err
-	}
-	req, err := http.NewRequest("POST", a.makeURL(urlStr), bytes.NewBuffer(b))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	return a.Do(ctx, req, result)
+// Put sends a form encoded PUT request to the urlStr and marshals the response into result
+func (a *API) Put(ctx context.Context, urlStr string, form url.Values, result interface{}) error {
+	return a.request(ctx, "PUT", urlStr, nil, form, result, encodeForm)
+}
+
+// PutJSON sends a PUT request to the API with the JSON encoded data
+func (a *API) PutJSON(ctx context.Context, urlStr string, data, result interface{}) error {
+	return a.request(ctx, "PUT", urlStr, nil, data, result, encodeJSON)
+}
+
+// Patch sends a PATCH request to the API with the JSON encoded data
+func (a *API) Patch(ctx context.Context, urlStr string, data, result interface{}) error {
+	return a.request(ctx, "PATCH", urlStr, nil, data, result, encodeJSON)
+}
+
+// Delete sends a DELETE request to the urlStr and marshals the response, if any, into result
+func (a *API) Delete(ctx context.Context, urlStr string, result interface{}) error {
+	return a.request(ctx, "DELETE", urlStr, nil, nil, result, encodeNone)
 }
 
 // Get sends a GET request to the urlStr and marshals the response into result
 func (a *API) Get(ctx context.Context, urlStr string, result interface{}) error {
-	req, err := http.NewRequest("GET", a.makeURL(urlStr), nil)
+	return a.request(ctx, "GET", urlStr, nil, nil, result, encodeNone)
+}
+
+// Request body encodings
+const (
+	encodeNone = ""
+	encodeForm = "form"
+	encodeJSON = "json"
+)
+
+// Request sends a request with the method to the path, which may have a query already,
+// and marshals the response into result. The query is added to the path's query.
+// A url.Values body is sent form encoded, any other non-nil body JSON encoded.
+// If the response has no content, result is left as is.
+func (a *API) Request(ctx context.Context, method, path string, query url.Values, body, result interface{}) error {
+	encoding := encodeJSON
+	switch body.(type) {
+	case nil:
+		encoding = encodeNone
+	case url.Values:
+		encoding = encodeForm
+	}
+	return a.request(ctx, method, path, query, body, result, encoding)
+}
+
+// request is Request with the body sent in the encoding, whatever its type. Form bodies
+// have to be url.Values.
+func (a *API) request(ctx context.Context, method, path string, query url.Values, body, result interface{}, encoding string) error {
+	urlStr := a.makeURL(path)
+	if len(query) > 0 {
+		sep := "?"
+		if strings.Contains(urlStr, "?") {
+			sep = "&"
+		}
+		urlStr += sep + query.Encode()
+	}
+
+	var r io.Reader
+	var contentType string
+	switch encoding {
+	case encodeForm:
+		form, _ := body.(url.Values)
+		r, contentType = strings.NewReader(form.Encode()), "application/x-www-form-urlencoded"
+	case encodeJSON:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r, contentType = bytes.NewReader(data), "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, r)
 	if err != nil {
 		return err
 	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
 	return a.Do(ctx, req, result)
 }
 
+// OrgURL returns the default base URL of the organization
+func OrgURL(orgID string) string {
+	return fmt.Sprintf("https://%s.memberclicks.net", orgID)
+}
+
+func (a *API) baseURL() string {
+	if a.BaseURL != "" {
+		return strings.TrimSuffix(a.BaseURL, "/")
+	}
+	return OrgURL(a.orgID)
+}
+
+// makeURL returns the absolute URL of urlStr. Absolute URLs returned by the API, like
+// NextPageURL, are changed to use the BaseURL.
 func (a *API) makeURL(urlStr string) string {
-	orgPrefix := fmt.Sprintf("https://%s.memberclicks.net", a.orgID)
-	urlStr = strings.TrimPrefix(urlStr, orgPrefix)
+	prefix := a.baseURL()
+	urlStr = strings.TrimPrefix(urlStr, prefix)
+	urlStr = strings.TrimPrefix(urlStr, OrgURL(a.orgID))
 	urlStr = strings.TrimPrefix(urlStr, "/")
-	return fmt.Sprintf("%s/%s", orgPrefix, urlStr)
+	return fmt.Sprintf("%s/%s", prefix, urlStr)
 }
 
-// Do sends the http.Request and marshals the JSON response into result
-func (a *API) Do(ctx context.Context, req *http.Request, result interface{}) error {
-
-	// If no authorization header already set, prefer accessToken
-	if a.accessToken != "" && req.Header.Get("Authorization") == "" {
-		req.Header.Set("Authorization", "Bearer "+a.accessToken)
+// Do sends the http.Request and marshals the JSON response into result. If the response
+// has no content, like a 204, result is left as is. Failed requests are sent again as
+// long as the RetryPolicy allows it.
+//
+// If req has no Authorization header, the client's access token is used, and renewed
+// if it is about to expire. If the server responds with 401 Unauthorized anyway,
+// the token is renewed and the request sent once more.
+//
+// To get the status, headers and other metadata of the response, use a context
+// from WithResponseMeta.
+func (a *API) Do(ctx context.Context, req *http.Request, result interface{}) (err error) {
+
+	ctx, span := tracing.StartRequest(ctx, a.TracerProvider, "memberclicks."+tracing.Operation(ctx, "Do"), req, tracing.OrgID.String(a.orgID))
+	// retries counts only the attempts the RetryPolicy scheduled, not the one after a reauthorization
+	retries := 0
+	defer func() {
+		var cb *callbackError
+		if errors.As(err, &cb) {
+			// The caller gets its callback's error back, which isn't the request's error
+			err = cb.err
+			tracing.End(span, retries, nil)
+			return
+		}
+		tracing.End(span, retries, err)
+	}()
+
+	if meta := responseMetaFrom(ctx); meta != nil {
+		// A meta reused for another call describes only that call
+		meta.Attempts = 0
+		start := time.Now()
+		defer func() { meta.Latency = time.Since(start) }()
 	}
 
-	// Otherwise, set basic auth.
-	if req.Header.Get("Authorization") == "" {
-		req.SetBasicAuth(a.clientID, a.clientSecret)
-	}
+	req = req.WithContext(ctx)
+	managed := req.Header.Get("Authorization") == "" && ctx.Value(noAuthKey{}) == nil
 
 	// Set other general headers.
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Accept", "application/json")
+	if a.UserAgent != "" {
+		req.Header.Set("User-Agent", a.UserAgent)
+	}
 
-	client := a.getClient(ctx)
-	resp, err := client.Do(req)
+	if err := makeReplayable(req); err != nil {
+		return err
+	}
 
-	// Store the last request/response combo
-	a.Lock()
-	a.lastRequest = req
-	a.lastResponse = resp
-	a.Unlock()
+	reauthed := false
+	for attempt := 1; ; attempt++ {
+		if managed {
+			if err := a.authorize(ctx, req); err != nil {
+				return err
+			}
+		}
+		err := a.sendAttempt(ctx, req, result)
+		if meta := responseMetaFrom(ctx); meta != nil {
+			meta.Attempts++
+		}
+		if err == nil || isCallbackError(err) {
+			return err
+		}
+		if managed && !reauthed && errors.Is(err, ErrUnauthorized) && a.expireToken(req) {
+			reauthed = true
+			attempt--
+		} else {
+			if a.RetryPolicy == nil {
+				return err
+			}
+			if s, ok := result.(streamDecoder); ok && s.started() {
+				return err
+			}
+			wait, ok := a.RetryPolicy.Retry(req, err, attempt)
+			if !ok {
+				return err
+			}
+			if err := sleep(ctx, wait); err != nil {
+				return err
+			}
+			retries++
+			a.metrics().ObserveRetry(MetricsClientAPI, req)
+		}
+		if err := rewind(req); err != nil {
+			return err
+		}
+	}
+}
 
+// send makes a single attempt at the request
+func (a *API) send(ctx context.Context, req *http.Request, result interface{}) (err error) {
+
+	if a.Limiter != nil {
+		start := time.Now()
+		err := a.Limiter.Wait(ctx)
+		a.metrics().ObserveRateLimitWait(MetricsClientAPI, time.Since(start))
+		if err != nil {
+			return err
+		}
+	}
+
+	adm, err := a.CircuitBreaker.allow()
 	if err != nil {
 		return err
 	}
+	// sendErr is the outcome of the network send for the CircuitBreaker, which doesn't
+	// count errors of decoding the response
+	var sendErr error
+	defer func() { a.CircuitBreaker.record(ctx, adm, sendErr) }()
+
+	var resp *http.Response
+	var bodyBytes []byte
+	var size int
+	start := time.Now()
+	defer func() {
+		d := time.Since(start)
+		logErr := err
+		if isCallbackError(err) {
+			logErr = nil
+		}
+		httplog.Log(ctx, a.Logger, req, resp, bodyBytes, logErr, d, a.RedactAttributes...)
+		statusCode := 0
+		if resp != nil {
+			statusCode = resp.StatusCode
+		}
+		a.metrics().ObserveRequest(MetricsClientAPI, req, statusCode, d, size)
+	}()
+
+	client, cancel := a.getClient(ctx)
+	defer cancel()
+	resp, err = client.Do(req)
+	if err != nil {
+		sendErr = err
+		return err
+	}
+
+	if meta := responseMetaFrom(ctx); meta != nil {
+		meta.record(resp)
+	}
+	tracing.SetStatus(ctx, resp.StatusCode)
 
 	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if s, ok := result.(streamDecoder); ok && resp.StatusCode < 400 && resp.StatusCode != http.StatusNoContent {
+		r := &countingReader{r: resp.Body}
+		err = s.decode(r)
+		size = r.n
+		// A body cut off in the middle is a failed send, unlike errors of decoding or the callback
+		sendErr = r.err
+		return err
+	}
+	bodyBytes, err = ioutil.ReadAll(resp.Body)
+	size = len(bodyBytes)
 	if err != nil {
+		sendErr = err
 		return err
 	}
 
 	if resp.StatusCode >= 400 {
-		errStr := string(bodyBytes)
-		if errStr == "" {
-			errStr = resp.Status
-		}
-		return errors.New(errStr)
+		apiErr := NewAPIError(resp, bodyBytes)
+		sendErr = apiErr
+		return apiErr
+	}
+	if resp.StatusCode == http.StatusNoContent || len(bodyBytes) == 0 || result == nil {
+		return nil
 	}
 
 	return json.Unmarshal(bodyBytes, result)
 }
 
-// LastRequest returns a copy of the last HTTP request make to the API
-func (a *API) LastRequest() *http.Request {
-	a.RLock()
-	defer a.RUnlock()
-	return &(*a.lastRequest)
-}
-
-// LastResponse returns a copy of the last HTTP request make to the API
-func (a *API) LastResponse() *http.Response {
-	a.RLock()
-	defer a.RUnlock()
-	return &(*a.lastResponse)
-}
-
-// New creates a new API client
-func New(orgID, clientID, clientSecret string) *API {
-	return &API{orgID: orgID, clientID: clientID, clientSecret: clientSecret}
+// New creates a new API client for the organization with the client credentials and options
+func New(orgID, clientID, clientSecret string, opts ...Option) (*API, error) {
+	if strings.TrimSpace(orgID) == "" {
+		return nil, ErrNoOrgID
+	}
+	a := &API{orgID: orgID, clientID: clientID, clientSecret: clientSecret}
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
 }
@@ -9,11 +9,11 @@ import (
 	"google.golang.org/appengine/urlfetch"
 )
 
-// Client returns an HTTP client
-func (a *API) getClient(ctx context.Context) *http.Client {
+// getClient returns an HTTP client and a func to call when done with the response
+func (a *API) getClient(ctx context.Context) (*http.Client, context.CancelFunc) {
 	if a.Client != nil {
-		return a.Client
+		return a.Client, func() {}
 	}
-	ctx, _ = context.WithTimeout(ctx, a.getTimeout())
-	return urlfetch.Client(ctx)
+	ctx, cancel := context.WithTimeout(ctx, a.getTimeout())
+	return urlfetch.Client(ctx), cancel
 }
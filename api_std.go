@@ -8,17 +8,12 @@ import (
 	"golang.org/x/net/context"
 )
 
-var (
-	httpClient *http.Client
-)
-
-// getClient returns an HTTP client
-func (a *API) getClient(ctx context.Context) *http.Client {
+// getClient returns an HTTP client and a func to call when done with the response.
+// Requests are bound to the context by Do, so there's nothing to cancel here.
+// The clients are cheap, the connections are pooled by the shared transport.
+func (a *API) getClient(ctx context.Context) (*http.Client, context.CancelFunc) {
 	if a.Client != nil {
-		return a.Client
-	}
-	if httpClient != nil {
-		return httpClient
+		return a.Client, func() {}
 	}
-	return &http.Client{Timeout: a.getTimeout()}
+	return &http.Client{Timeout: a.getTimeout(), Transport: a.transport()}, func() {}
 }
@@ -1,3 +1,82 @@
 // +build !appengine
 
 package memberclicks
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bradberger/go-memberclicks/memberclickstest"
+)
+
+func TestGetClientTransport(t *testing.T) {
+	a := newAPI(t)
+	c, _ := a.getClient(ctx)
+	assert.Equal(t, DefaultTransport, c.Transport)
+	assert.Equal(t, Timeout, c.Timeout)
+
+	tr := NewTransport()
+	a = newAPI(t, WithTransport(tr))
+	c, _ = a.getClient(ctx)
+	assert.Equal(t, tr, c.Transport)
+}
+
+// BenchmarkProfileCrawl crawls all pages of profiles over TLS with a new client per
+// crawl, like a web handler creating a client per request does.
+func BenchmarkProfileCrawl(b *testing.B) {
+	srv := memberclickstest.NewTLSServer(nil)
+	defer srv.Close()
+	for i := 0; i < 500; i++ {
+		srv.AddProfile(map[string]interface{}{"[Profile ID]": i + 1, "[Contact Name]": fmt.Sprintf("Member %d", i+1)})
+	}
+	tlsConfig := srv.Client().Transport.(*http.Transport).TLSClientConfig
+
+	crawl := func(b *testing.B, tr http.RoundTripper) {
+		a, err := New("test", memberclickstest.DefaultClientID, memberclickstest.DefaultClientSecret, WithBaseURL(srv.URL), WithTransport(tr))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := a.Auth(ctx); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := a.Profiles(ctx, 0, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+	newTransport := func(t *http.Transport, tlsConfig *tls.Config) *http.Transport {
+		t.TLSClientConfig = tlsConfig.Clone()
+		return t
+	}
+
+	b.Run("TransportPerClient", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				tr := newTransport(NewTransport(), tlsConfig)
+				crawl(b, tr)
+				tr.CloseIdleConnections()
+			}
+		})
+	})
+	b.Run("SharedHTTPDefaultTransport", func(b *testing.B) {
+		tr := newTransport(http.DefaultTransport.(*http.Transport).Clone(), tlsConfig)
+		defer tr.CloseIdleConnections()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				crawl(b, tr)
+			}
+		})
+	})
+	b.Run("SharedTunedTransport", func(b *testing.B) {
+		tr := newTransport(NewTransport(), tlsConfig)
+		defer tr.CloseIdleConnections()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				crawl(b, tr)
+			}
+		})
+	})
+}
@@ -1,21 +1,197 @@
 package memberclicks
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
+	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
 
+	"github.com/bradberger/go-memberclicks/memberclickstest"
 	_ "github.com/joho/godotenv/autoload"
 )
 
 var (
 	ctx = context.Background()
-	mc  = New(os.Getenv("MEMBERCLICKS_ORG_ID"), os.Getenv("MEMBERCLICKS_CLIENT_ID"), os.Getenv("MEMBERCLICKS_CLIENT_SECRET"))
+	mc  *API
 )
 
+// init uses the MemberClicks organization of the environment if there is one,
+// and the fake server otherwise
 func init() {
+	var err error
+	if os.Getenv(EnvOrgID) != "" {
+		mc, err = NewFromEnv()
+	} else {
+		srv := memberclickstest.NewServer(nil)
+		mc, err = New("test", memberclickstest.DefaultClientID, memberclickstest.DefaultClientSecret, WithBaseURL(srv.URL))
+	}
+	if err != nil {
+		log.Fatalf("Could not create client: %v", err)
+	}
 	if err := mc.Auth(ctx); err != nil {
 		log.Fatalf("Could not authorize with MemberClicks: %v", err)
 	}
 }
+
+// newAPI returns a client for the demo organization
+func newAPI(t testing.TB, opts ...Option) *API {
+	a, err := New("demo", "id", "secret", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(" ", "id", "secret")
+	assert.Equal(t, ErrNoOrgID, err)
+	_, err = New("demo", "id", "secret", WithBaseURL("localhost:8080"))
+	assert.Error(t, err)
+	_, err = New("demo", "id", "secret", WithTimeout(0))
+	assert.Error(t, err)
+
+	c := &http.Client{}
+	a, err := New("demo", "id", "secret", WithHTTPClient(c), WithTimeout(time.Second), WithBaseURL("http://localhost:8080"),
+		WithUserAgent("test/1.0"), WithAccessToken("abc"), WithScopes("read", "write"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, c, a.Client)
+	assert.Equal(t, time.Second, a.getTimeout())
+	assert.Equal(t, "http://localhost:8080", a.baseURL())
+	assert.Equal(t, "abc", a.accessToken)
+	assert.Equal(t, "read write", a.scope())
+}
+
+func TestAPIUserAgent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name":%q}`, r.UserAgent())
+	}))
+	defer srv.Close()
+
+	a := newAPI(t, WithBaseURL(srv.URL), WithAccessToken("abc"), WithUserAgent("test/1.0"))
+	var res Group
+	assert.NoError(t, a.Get(ctx, "/api/v1/group", &res))
+	assert.Equal(t, "test/1.0", res.Name)
+}
+
+func TestAPIMakeURL(t *testing.T) {
+	a := newAPI(t)
+	assert.Equal(t, "https://demo.memberclicks.net/api/v1/group", a.makeURL("/api/v1/group"))
+	assert.Equal(t, "https://demo.memberclicks.net/api/v1/group", a.makeURL("api/v1/group"))
+	assert.Equal(t, "https://demo.memberclicks.net/api/v1/group", a.makeURL("https://demo.memberclicks.net/api/v1/group"))
+
+	a.BaseURL = "http://127.0.0.1:8080/"
+	assert.Equal(t, "http://127.0.0.1:8080/api/v1/group", a.makeURL("/api/v1/group"))
+	assert.Equal(t, "http://127.0.0.1:8080/api/v1/profile?pageNumber=2", a.makeURL("https://demo.memberclicks.net/api/v1/profile?pageNumber=2"))
+	assert.Equal(t, "http://127.0.0.1:8080/api/v1/profile?pageNumber=2", a.makeURL("http://127.0.0.1:8080/api/v1/profile?pageNumber=2"))
+	assert.True(t, strings.HasPrefix(a.GetAuthCodeURL("read", "state", "/"), "http://127.0.0.1:8080/oauth/v1/authorize?"))
+	assert.Equal(t, "http://127.0.0.1:8080/oauth/v1/token", a.OAuth2Config("/").Endpoint.TokenURL)
+}
+
+func TestAPILogger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"[Profile ID]":1,"[Email | Primary]":"jdoe@example.com"}`))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	a := newAPI(t, WithBaseURL(srv.URL))
+	a.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	a.RedactAttributes = []string{"[Email | Primary]"}
+	a.SetAccessToken("abc")
+
+	_, err := a.Profile(ctx, "1")
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "path=/api/v1/profile/1")
+	assert.Contains(t, buf.String(), "status=200")
+	assert.NotContains(t, buf.String(), "jdoe@example.com")
+	assert.NotContains(t, buf.String(), "Bearer abc")
+}
+
+func TestAPILoggerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		if r.URL.Path == "/oauth/v1/token" {
+			w.Write([]byte(`{"access_token":"leaked"}`))
+			return
+		}
+		w.Write([]byte(`{"status":400,"error":"Bad Request","message":"Invalid page size"}`))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	a := newAPI(t, WithBaseURL(srv.URL))
+	a.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+
+	// The raw body is the error message, but only the status is logged at info level
+	assert.EqualError(t, a.Auth(ctx), `{"access_token":"leaked"}`)
+	assert.Contains(t, buf.String(), "error.status=400")
+	assert.NotContains(t, buf.String(), "leaked")
+
+	buf.Reset()
+	a.SetAccessToken("abc")
+	_, err := a.Groups(ctx)
+	assert.Error(t, err)
+	assert.Contains(t, buf.String(), `error.status=400 error.code="Bad Request" error.message="Invalid page size"`)
+}
+
+func TestAPIRequest(t *testing.T) {
+	type echo struct {
+		Method, Query, ContentType, Body string
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/empty" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(echo{r.Method, r.URL.RawQuery, r.Header.Get("Content-Type"), string(b)})
+	}))
+	defer srv.Close()
+
+	a := newAPI(t, WithBaseURL(srv.URL), WithAccessToken("abc"))
+
+	var e echo
+	assert.NoError(t, a.Request(ctx, "GET", "/api/v1/profile?searchId=1", url.Values{"pageNumber": {"2"}}, nil, &e))
+	assert.Equal(t, echo{Method: "GET", Query: "searchId=1&pageNumber=2"}, e)
+
+	assert.NoError(t, a.Put(ctx, "/api/v1/foo", url.Values{"a": {"b"}}, &e))
+	assert.Equal(t, echo{"PUT", "", "application/x-www-form-urlencoded", "a=b"}, e)
+
+	assert.NoError(t, a.PutJSON(ctx, "/api/v1/foo", map[string]int{"a": 1}, &e))
+	assert.Equal(t, echo{"PUT", "", "application/json", `{"a":1}`}, e)
+
+	assert.NoError(t, a.Patch(ctx, "/api/v1/foo", map[string]int{"a": 2}, &e))
+	assert.Equal(t, echo{"PATCH", "", "application/json", `{"a":2}`}, e)
+
+	assert.NoError(t, a.Delete(ctx, "/api/v1/foo", nil))
+
+	e = echo{Method: "unchanged"}
+	assert.NoError(t, a.Delete(ctx, "/api/v1/empty", &e))
+	assert.Equal(t, "unchanged", e.Method)
+
+	// The JSON helpers always send JSON, like they did before Request
+	assert.NoError(t, a.PostJSON(ctx, "/api/v1/foo", url.Values{"a": {"b"}}, &e))
+	assert.Equal(t, echo{"POST", "", "application/json", `{"a":["b"]}`}, e)
+	assert.NoError(t, a.PostJSON(ctx, "/api/v1/foo", nil, &e))
+	assert.Equal(t, echo{"POST", "", "application/json", "null"}, e)
+	assert.NoError(t, a.Post(ctx, "/api/v1/foo", nil, &e))
+	assert.Equal(t, echo{"POST", "", "application/x-www-form-urlencoded", ""}, e)
+}
@@ -0,0 +1,228 @@
+package memberclicks
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ErrCircuitOpen is returned without sending the request while the CircuitBreaker is open
+var ErrCircuitOpen = errors.New("memberclicks: circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState int
+
+// Circuit breaker states
+const (
+	// CircuitClosed lets all requests through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails all requests with ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen lets probe requests through to find out if the servers recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker fails requests fast while the MemberClicks servers are down. It opens
+// when the rate of failed requests in a window exceeds FailureRate, and after OpenTimeout
+// lets Probes requests through. If they all succeed it closes again, otherwise it reopens.
+//
+// Only 5XX responses and network errors, including responses cut off while they are
+// read, are failures. Other responses, like the 4XX responses to bad requests, count as
+// successes. Canceled requests, and errors before or after the request is sent, like
+// rate limiter or decoding errors, aren't counted.
+// Requests shared with Dedupe are counted once.
+type CircuitBreaker struct {
+	// FailureRate between 0 and 1 opens the breaker
+	FailureRate float64
+	// MinRequests is how many requests there have to be in the window before it can open
+	MinRequests int
+	// Window is how long failures are counted for before the counts are reset
+	Window time.Duration
+	// OpenTimeout is how long the breaker stays open before probing
+	OpenTimeout time.Duration
+	// Probes is how many successful requests in the half-open state close the breaker
+	Probes int
+
+	// OnStateChange, if set, is called when the state changes, for example to alert.
+	// It's called with the breaker locked, so it must not call the breaker's methods.
+	OnStateChange func(from, to CircuitState)
+
+	mu             sync.Mutex
+	state          CircuitState
+	windowStart    time.Time
+	requests       int
+	failures       int
+	openedAt       time.Time
+	probes         int
+	probeSuccesses int
+}
+
+// NewCircuitBreaker returns a CircuitBreaker which opens when at least half of at least
+// 10 requests in a minute fail, and probes with one request after 30 seconds.
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureRate: 0.5,
+		MinRequests: 10,
+		Window:      time.Minute,
+		OpenTimeout: 30 * time.Second,
+		Probes:      1,
+	}
+}
+
+// State returns the current state, for example for health checks
+func (b *CircuitBreaker) State() CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.halfOpen(time.Now())
+	return b.state
+}
+
+// Reset closes the breaker and clears the counts
+func (b *CircuitBreaker) Reset() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.setState(CircuitClosed, time.Now())
+}
+
+// admission is how allow let a request through, for record
+type admission struct {
+	// probe is set for the probes of a half-open breaker, which opened at openedAt
+	probe    bool
+	openedAt time.Time
+}
+
+// allow returns ErrCircuitOpen if a request may not be sent now
+func (b *CircuitBreaker) allow() (admission, error) {
+	if b == nil {
+		return admission{}, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.halfOpen(time.Now())
+	switch b.state {
+	case CircuitOpen:
+		return admission{}, ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.probes >= b.probeCount() {
+			return admission{}, ErrCircuitOpen
+		}
+		b.probes++
+		return admission{probe: true, openedAt: b.openedAt}, nil
+	}
+	return admission{}, nil
+}
+
+// record counts the outcome of a request which allow let through
+func (b *CircuitBreaker) record(ctx context.Context, adm admission, err error) {
+	if b == nil {
+		return
+	}
+	failed, counted := isFailure(ctx, err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+
+	if b.state == CircuitHalfOpen {
+		// Only the probes of this half-open state say whether the servers recovered.
+		// Requests let through before it opened, or by an earlier half-open state, don't.
+		if !adm.probe || !adm.openedAt.Equal(b.openedAt) {
+			return
+		}
+		b.probes--
+		switch {
+		case !counted:
+		case failed:
+			b.setState(CircuitOpen, now)
+		default:
+			if b.probeSuccesses++; b.probeSuccesses >= b.probeCount() {
+				b.setState(CircuitClosed, now)
+			}
+		}
+		return
+	}
+	if b.state != CircuitClosed || !counted {
+		return
+	}
+
+	if b.Window > 0 && now.Sub(b.windowStart) > b.Window {
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if b.requests >= b.MinRequests && float64(b.failures) >= b.FailureRate*float64(b.requests) && b.failures > 0 {
+		b.setState(CircuitOpen, now)
+	}
+}
+
+// halfOpen moves an open breaker to half-open after the OpenTimeout. The caller must hold the lock.
+func (b *CircuitBreaker) halfOpen(now time.Time) {
+	if b.state == CircuitOpen && now.Sub(b.openedAt) >= b.OpenTimeout {
+		b.setState(CircuitHalfOpen, now)
+	}
+}
+
+// setState changes the state and resets the counts. The caller must hold the lock.
+func (b *CircuitBreaker) setState(state CircuitState, now time.Time) {
+	from := b.state
+	b.state = state
+	b.windowStart, b.requests, b.failures = now, 0, 0
+	b.probes, b.probeSuccesses = 0, 0
+	if state == CircuitOpen {
+		b.openedAt = now
+	}
+	if from != state && b.OnStateChange != nil {
+		b.OnStateChange(from, state)
+	}
+}
+
+func (b *CircuitBreaker) probeCount() int {
+	if b.Probes < 1 {
+		return 1
+	}
+	return b.Probes
+}
+
+// isFailure reports whether the outcome of a network send means the servers are failing,
+// and whether it should be counted at all
+func isFailure(ctx context.Context, err error) (failed, counted bool) {
+	if err == nil {
+		return false, true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500, true
+	}
+	// The caller gave up, which says nothing about the servers
+	if ctx.Err() != nil {
+		return false, false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true, true
+	}
+	return false, false
+}
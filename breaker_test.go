@@ -0,0 +1,179 @@
+package memberclicks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks/memberclickstest"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	var changes []string
+	b := &CircuitBreaker{FailureRate: 0.5, MinRequests: 4, Window: time.Minute, OpenTimeout: 50 * time.Millisecond, Probes: 2}
+	b.OnStateChange = func(from, to CircuitState) { changes = append(changes, from.String()+">"+to.String()) }
+	a, err := New("test", memberclickstest.DefaultClientID, memberclickstest.DefaultClientSecret, WithBaseURL(srv.URL))
+	if !assert.NoError(t, err) {
+		return
+	}
+	a.CircuitBreaker = b
+	assert.NoError(t, a.Auth(ctx))
+
+	// 4XX errors are the caller's fault
+	for i := 0; i < 5; i++ {
+		_, err := a.Profile(ctx, "1")
+		assert.True(t, errors.Is(err, ErrNotFound))
+	}
+	assert.Equal(t, CircuitClosed, b.State())
+	b.Reset()
+
+	srv.Inject(memberclickstest.Fault{Path: "/api/v1/group", StatusCode: http.StatusInternalServerError})
+	for i := 0; i < 4; i++ {
+		_, err := a.Groups(ctx)
+		assert.True(t, errors.Is(err, ErrServer))
+	}
+	assert.Equal(t, CircuitOpen, b.State())
+	_, err = a.Groups(ctx)
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, 4, srv.Requests("GET /api/v1/group"), "open breaker doesn't send requests")
+
+	// A failed probe opens it again
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	_, err = a.Groups(ctx)
+	assert.True(t, errors.Is(err, ErrServer))
+	assert.Equal(t, CircuitOpen, b.State())
+
+	srv.ClearFaults()
+	time.Sleep(60 * time.Millisecond)
+	_, err = a.Groups(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	_, err = a.Groups(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, CircuitClosed, b.State())
+	assert.Equal(t, []string{"closed>open", "open>half-open", "half-open>open", "open>half-open", "half-open>closed"}, changes)
+}
+
+func TestCircuitBreakerIsFailure(t *testing.T) {
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	for _, tt := range []struct {
+		ctx             context.Context
+		err             error
+		failed, counted bool
+	}{
+		{ctx, nil, false, true},
+		{ctx, &APIError{StatusCode: 503}, true, true},
+		{ctx, &APIError{StatusCode: 429}, false, true},
+		{ctx, &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true, true},
+		{ctx, io.ErrUnexpectedEOF, true, true},
+		{ctx, errors.New("callback failed"), false, false},
+		{ctx, &json.SyntaxError{}, false, false},
+		{canceled, context.Canceled, false, false},
+	} {
+		failed, counted := isFailure(tt.ctx, tt.err)
+		assert.Equal(t, tt.failed, failed, "%v", tt.err)
+		assert.Equal(t, tt.counted, counted, "%v", tt.err)
+	}
+}
+
+func TestCircuitBreakerCounts(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	b := &CircuitBreaker{FailureRate: 0.5, MinRequests: 100, Window: time.Minute}
+	a, _ := New("test", memberclickstest.DefaultClientID, memberclickstest.DefaultClientSecret, WithBaseURL(srv.URL), WithDedupe())
+	assert.NoError(t, a.Auth(ctx))
+	a.CircuitBreaker = b
+
+	// Errors of the caller's own code are no server failures
+	stop := errors.New("stop")
+	assert.Equal(t, stop, a.EachProfile(ctx, 1, 10, func(*Profile) error { return stop }))
+	a.Use(func(next Handler) Handler {
+		return func(ctx context.Context, req *http.Request, result interface{}) error {
+			if req.URL.Path == "/api/v1/event" {
+				return errors.New("rejected by middleware")
+			}
+			return next(ctx, req, result)
+		}
+	})
+	assert.Error(t, a.Get(ctx, "/api/v1/event", nil))
+	assert.Equal(t, 1, b.requests)
+	assert.Equal(t, 0, b.failures)
+
+	// Deduplicated callers share one network send, which is counted once
+	srv.Inject(memberclickstest.Fault{Path: "/api/v1/group", StatusCode: http.StatusInternalServerError, Latency: 50 * time.Millisecond})
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.True(t, errors.Is(a.Get(ctx, "/api/v1/group", nil), ErrServer))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, srv.Requests("GET /api/v1/group"))
+	assert.Equal(t, 2, b.requests)
+	assert.Equal(t, 1, b.failures)
+}
+
+func TestCircuitBreakerStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"totalPageCount":1,"profiles":[{"[Profile ID]":1},`)
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer srv.Close()
+
+	b := &CircuitBreaker{FailureRate: 0.5, MinRequests: 100, Window: time.Minute}
+	a := newAPI(t, WithBaseURL(srv.URL), WithAccessToken("abc"))
+	a.CircuitBreaker = b
+
+	// A page cut off in the middle is a failure, even though profiles were handed out
+	assert.Error(t, a.EachProfile(ctx, 1, 10, func(*Profile) error { return nil }))
+	assert.Equal(t, 1, b.requests)
+	assert.Equal(t, 1, b.failures)
+}
+
+func TestCircuitBreakerProbes(t *testing.T) {
+	b := &CircuitBreaker{FailureRate: 0.5, MinRequests: 1, Window: time.Minute, OpenTimeout: time.Hour, Probes: 1}
+	closed, err := b.allow()
+	assert.NoError(t, err)
+	b.record(ctx, admission{}, &APIError{StatusCode: 500})
+	assert.Equal(t, CircuitOpen, b.State())
+
+	// A request let through while the breaker was closed isn't a probe
+	b.openedAt = time.Now().Add(-2 * time.Hour)
+	probe, err := b.allow()
+	assert.NoError(t, err)
+	b.record(ctx, closed, nil)
+	assert.Equal(t, 1, b.probes)
+	_, err = b.allow()
+	assert.Equal(t, ErrCircuitOpen, err, "the probe is still in flight")
+
+	b.record(ctx, probe, nil)
+	assert.Equal(t, CircuitClosed, b.State())
+	assert.Equal(t, 0, b.probes)
+}
+
+func TestCircuitBreakerNil(t *testing.T) {
+	var b *CircuitBreaker
+	_, err := b.allow()
+	assert.NoError(t, err)
+	assert.Equal(t, CircuitClosed, b.State())
+}
@@ -0,0 +1,196 @@
+package memberclicks
+
+import (
+	"container/list"
+	stdcontext "context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Reference data endpoints, the keys of the API CacheTTL
+const (
+	CountriesPath      = "/api/v1/country"
+	EventsPath         = "/api/v1/event"
+	GroupsPath         = "/api/v1/group"
+	MemberStatusesPath = "/api/v1/member-status"
+	MemberTypesPath    = "/api/v1/member-type"
+)
+
+var (
+	// DefaultCacheTTL is how long cached reference data is fresh if the endpoint has no CacheTTL
+	DefaultCacheTTL = time.Hour
+
+	_ Cache = (*LRUCache)(nil)
+)
+
+// Cache stores the responses of the reference data endpoints
+type Cache interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, e *CacheEntry)
+	Delete(key string)
+}
+
+// CacheEntry is a cached response body
+type CacheEntry struct {
+	Body    []byte
+	Expires time.Time
+}
+
+// LRUCache is an in-memory Cache which evicts the least recently used entries
+type LRUCache struct {
+	size    int
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type lruItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewLRUCache returns an in-memory cache which holds up to size entries
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{size: size, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// Get implements the Cache interface
+func (c *LRUCache) Get(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+// Set implements the Cache interface
+func (c *LRUCache) Set(key string, e *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*lruItem).entry = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruItem{key: key, entry: e})
+	for c.size > 0 && c.order.Len() > c.size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*lruItem).key)
+	}
+}
+
+// Delete implements the Cache interface
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+// InvalidateCache removes the cached responses of the reference data endpoints with
+// the given paths, or of all of them if there are no paths.
+func (a *API) InvalidateCache(paths ...string) {
+	if a.Cache == nil {
+		return
+	}
+	if len(paths) == 0 {
+		paths = []string{CountriesPath, EventsPath, GroupsPath, MemberStatusesPath, MemberTypesPath}
+	}
+	for _, p := range paths {
+		a.Cache.Delete(a.cacheKey(p))
+	}
+}
+
+func (a *API) cacheKey(path string) string {
+	return a.baseURL() + path
+}
+
+func (a *API) cacheTTL(path string) time.Duration {
+	if ttl, ok := a.CacheTTL[path]; ok {
+		return ttl
+	}
+	return DefaultCacheTTL
+}
+
+// getCached is Get for the reference data endpoints, using the Cache if there is one.
+// Expired entries are still returned for CacheStale while they are refreshed in the background.
+func (a *API) getCached(ctx context.Context, path string, result interface{}) error {
+	if a.Cache == nil {
+		return a.Get(ctx, path, result)
+	}
+	key := a.cacheKey(path)
+	if e, ok := a.Cache.Get(key); ok {
+		now := time.Now()
+		if now.Before(e.Expires) {
+			return json.Unmarshal(e.Body, result)
+		}
+		if now.Before(e.Expires.Add(a.CacheStale)) {
+			// Detached from the request, which may be done long before the refresh
+			go a.refreshCache(WithResponseMeta(stdcontext.WithoutCancel(ctx), nil), path, key)
+			return json.Unmarshal(e.Body, result)
+		}
+	}
+	body, err := a.refreshCache(ctx, path, key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, result)
+}
+
+// sharedResponse is the response to a request shared by many callers, and its metadata
+type sharedResponse struct {
+	body []byte
+	meta ResponseMeta
+}
+
+// refreshCache gets the response body of the path and caches it. Concurrent refreshes of
+// the same key share one request, which runs detached from the callers, so that one of
+// them giving up doesn't fail the others. Each caller stops waiting when its ctx is done,
+// and gets its own copy of the response metadata and API error of the shared request.
+func (a *API) refreshCache(ctx context.Context, path, key string) ([]byte, error) {
+	ch := a.cacheGroup.DoChan(key, func() (interface{}, error) {
+		res := &sharedResponse{}
+		ctx, cancel := a.detach(WithResponseMeta(ctx, &res.meta))
+		defer cancel()
+		var body json.RawMessage
+		if err := a.Get(ctx, path, &body); err != nil {
+			return res, err
+		}
+		res.body = body
+		a.Cache.Set(key, &CacheEntry{Body: body, Expires: time.Now().Add(a.cacheTTL(path))})
+		return res, nil
+	})
+	select {
+	case r := <-ch:
+		res := r.Val.(*sharedResponse)
+		if meta := responseMetaFrom(ctx); meta != nil {
+			meta.recordShared(&res.meta)
+			meta.Attempts, meta.Latency = res.meta.Attempts, res.meta.Latency
+		}
+		if apiErr, ok := r.Err.(*APIError); ok {
+			return nil, apiErr.clone()
+		}
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return res.body, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// detach returns a context for work shared by many callers, or outliving the caller, which
+// is bounded by the client's timeout instead of ctx. It has the values of ctx, so the shared
+// work records into the ResponseMeta of ctx, which mustn't be a caller's.
+func (a *API) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(stdcontext.WithoutCancel(ctx), a.getTimeout())
+}
@@ -0,0 +1,181 @@
+package memberclicks
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks/memberclickstest"
+)
+
+func newCachedAPI(srv *memberclickstest.Server) *API {
+	a, _ := New("test", memberclickstest.DefaultClientID, memberclickstest.DefaultClientSecret, WithBaseURL(srv.URL))
+	a.Cache = NewLRUCache(10)
+	return a
+}
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+	c.Set("a", &CacheEntry{Body: []byte("a")})
+	c.Set("b", &CacheEntry{Body: []byte("b")})
+	c.Get("a")
+	c.Set("c", &CacheEntry{Body: []byte("c")})
+
+	_, ok := c.Get("b")
+	assert.False(t, ok, "least recently used entry is evicted")
+	e, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "a", string(e.Body))
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestCache(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+	a := newCachedAPI(srv)
+	assert.NoError(t, a.Auth(ctx))
+
+	g, err := a.Groups(ctx)
+	assert.NoError(t, err)
+	g[0].Name = "changed"
+	g, err = a.Groups(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "Board", g[0].Name, "callers get their own copy")
+	assert.Equal(t, 1, srv.Requests("GET /api/v1/group"))
+
+	a.InvalidateCache(GroupsPath)
+	_, err = a.Groups(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, srv.Requests("GET /api/v1/group"))
+
+	a.CacheTTL = map[string]time.Duration{CountriesPath: -time.Second}
+	_, err = a.Countries(ctx)
+	assert.NoError(t, err)
+	_, err = a.Countries(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, srv.Requests("GET /api/v1/country"), "expired entries are fetched again")
+}
+
+func TestCacheStale(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+	a := newCachedAPI(srv)
+	a.CacheTTL = map[string]time.Duration{EventsPath: -time.Second}
+	a.CacheStale = time.Hour
+	assert.NoError(t, a.Auth(ctx))
+
+	_, err := a.Events(ctx)
+	assert.NoError(t, err)
+
+	srv.Inject(memberclickstest.Fault{Path: EventsPath, Latency: 50 * time.Millisecond})
+	start := time.Now()
+	e, err := a.Events(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, e, 1)
+	assert.True(t, time.Since(start) < 50*time.Millisecond, "stale entry is returned without waiting")
+	assert.Eventually(t, func() bool { return srv.Requests("GET /api/v1/event") == 2 }, time.Second, 10*time.Millisecond)
+}
+
+func TestCacheCollapse(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+	a := newCachedAPI(srv)
+	assert.NoError(t, a.Auth(ctx))
+
+	srv.Inject(memberclickstest.Fault{Path: MemberTypesPath, Latency: 50 * time.Millisecond})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := a.MemberTypes(ctx)
+			assert.NoError(t, err)
+			assert.Len(t, m, 2)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, srv.Requests("GET /api/v1/member-type"))
+}
+
+func TestCacheCollapseCancel(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+	a := newCachedAPI(srv)
+	assert.NoError(t, a.Auth(ctx))
+	srv.Inject(memberclickstest.Fault{Path: MemberTypesPath, Latency: 100 * time.Millisecond})
+
+	// The first caller gives up, the second one still gets the shared response
+	first, cancel := context.WithCancel(ctx)
+	errc := make(chan error)
+	go func() {
+		_, err := a.MemberTypes(first)
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m, err := a.MemberTypes(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, m, 2)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.Equal(t, context.Canceled, <-errc, "the canceled caller returns without waiting")
+	<-done
+	assert.Equal(t, 1, srv.Requests("GET /api/v1/member-type"))
+}
+
+func TestCacheMeta(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+	a := newCachedAPI(srv)
+	assert.NoError(t, a.Auth(ctx))
+	srv.Inject(memberclickstest.Fault{Path: GroupsPath, Header: http.Header{"X-Request-Id": {"abc"}}})
+
+	// A miss records the shared request
+	var meta ResponseMeta
+	_, err := a.Groups(WithResponseMeta(ctx, &meta))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, meta.StatusCode)
+	assert.Equal(t, "abc", meta.RequestID)
+	assert.Equal(t, 1, meta.Attempts)
+
+	// A hit sends no request, so there is nothing to record
+	var hit ResponseMeta
+	_, err = a.Groups(WithResponseMeta(ctx, &hit))
+	assert.NoError(t, err)
+	assert.Equal(t, ResponseMeta{}, hit)
+}
+
+func TestCacheError(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+	a := newCachedAPI(srv)
+	assert.NoError(t, a.Auth(ctx))
+	srv.Inject(memberclickstest.Fault{Path: GroupsPath, StatusCode: http.StatusServiceUnavailable, Latency: 50 * time.Millisecond})
+
+	errs := make([]*APIError, 2)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := a.Groups(ctx)
+			assert.True(t, errors.As(err, &errs[i]))
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, srv.Requests("GET /api/v1/group"))
+	if errs[0] != nil && errs[1] != nil {
+		assert.True(t, errs[0] != errs[1], "callers have their own copy of the error")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
@@ -15,6 +16,11 @@ import (
 	"time"
 
 	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks"
+	"github.com/bradberger/go-memberclicks/internal/httplog"
+	"github.com/bradberger/go-memberclicks/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -41,14 +47,27 @@ type AuthResponse struct {
 	Password      string `json:"password"`
 }
 
-// New returns a new Classic instance with the given authToken
-func New(ctx context.Context, orgID, apiKey, username, password string) (*Client, error) {
+// New returns a new Classic client for the organization with the options, and authenticates
+// it unless the WithToken or WithoutAuth options are used. If the authentication fails,
+// the client is returned with the error.
+func New(ctx context.Context, orgID, apiKey, username, password string, opts ...Option) (*Client, error) {
+	if strings.TrimSpace(orgID) == "" {
+		return nil, memberclicks.ErrNoOrgID
+	}
 	api := &Client{
 		OrganizationID: orgID,
 		apiKey:         apiKey,
 		username:       username,
 		password:       password,
 	}
+	for _, opt := range opts {
+		if err := opt(api); err != nil {
+			return nil, err
+		}
+	}
+	if api.skipAuth || api.token != "" {
+		return api, nil
+	}
 
 	return api, api.Auth(ctx)
 }
@@ -58,12 +77,44 @@ type Client struct {
 	apiKey, username, password, token string
 	OrganizationID                    string
 
+	// BaseURL is the URL requests are sent to, for example a proxy or a test server.
+	// If empty, the organization's own https://{orgID}.memberclicks.net is used.
+	BaseURL string
+
 	HttpClient *http.Client
 
+	// Transport sends the requests if there's no HttpClient. If nil, the memberclicks
+	// DefaultTransport is used, which is shared with the other clients.
+	Transport http.RoundTripper
+
+	// Timeout is the duration before requests time out. If 0, there's no timeout,
+	// except on App Engine where it's 15 seconds.
+	Timeout time.Duration
+
+	// UserAgent, if set, is the User-Agent header of requests
+	UserAgent string
+
+	// Limiter, if set, is waited on before each request is sent.
+	Limiter memberclicks.Limiter
+
+	// Logger, if set, logs every request. Headers and bodies are logged at debug level,
+	// with credentials, tokens and the RedactAttributes profile attributes redacted.
+	Logger           *slog.Logger
+	RedactAttributes []string
+
+	// TracerProvider creates the spans for requests. If nil, the global provider is used.
+	TracerProvider trace.TracerProvider
+
+	// Metrics, if set, collects metrics about the requests, for example a prommetrics.Metrics.
+	Metrics memberclicks.Metrics
+
+	skipAuth bool
+
 	sync.Mutex
 }
 
 func (c *Client) Auth(ctx context.Context) error {
+	ctx = tracing.WithOperation(ctx, "Auth")
 	var auth AuthResponse
 	form := url.Values{"apiKey": {c.apiKey}, "username": {c.username}, "password": {c.password}}
 	if _, err := c.Post(ctx, "/services/auth", form, &auth); err != nil {
@@ -74,11 +125,16 @@ func (c *Client) Auth(ctx context.Context) error {
 }
 
 func (c *Client) getEndpoint() string {
-	return fmt.Sprintf("https://%s.memberclicks.net", c.OrganizationID)
+	if c.BaseURL != "" {
+		return strings.TrimSuffix(c.BaseURL, "/")
+	}
+	return memberclicks.OrgURL(c.OrganizationID)
 }
 
 func (c *Client) makeURL(uri string) string {
-	return fmt.Sprintf("%s/%s", c.getEndpoint(), strings.TrimPrefix(strings.TrimPrefix(uri, c.getEndpoint()), "/"))
+	uri = strings.TrimPrefix(uri, c.getEndpoint())
+	uri = strings.TrimPrefix(uri, memberclicks.OrgURL(c.OrganizationID))
+	return fmt.Sprintf("%s/%s", c.getEndpoint(), strings.TrimPrefix(uri, "/"))
 }
 
 // Post sends a HTTP Post request to the given url and returns the result decoded into respDatc.
@@ -136,6 +192,9 @@ func (c *Client) NewRequest(method string, urlStr string, body io.Reader) (*http
 		return nil, err
 	}
 	req.Header.Set("Accept", "application/json")
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
 	if c.token != "" {
 		req.Header.Set("Authorization", c.token)
 	}
@@ -146,6 +205,7 @@ func (c *Client) NewRequest(method string, urlStr string, body io.Reader) (*http
 }
 
 func (c *Client) Users(ctx context.Context, params url.Values) (*UserList, error) {
+	ctx = tracing.WithOperation(ctx, "Users")
 	var l UserList
 	urlStr := "/services/user"
 	if params != nil {
@@ -158,6 +218,7 @@ func (c *Client) Users(ctx context.Context, params url.Values) (*UserList, error
 }
 
 func (c *Client) User(ctx context.Context, userID string) (*Profile, error) {
+	ctx = tracing.WithOperation(ctx, "User")
 	var p Profile
 	if _, err := c.Get(ctx, "/services/user/"+userID, &p); err != nil {
 		return nil, err
@@ -165,31 +226,53 @@ func (c *Client) User(ctx context.Context, userID string) (*Profile, error) {
 	return &p, nil
 }
 
-func (c *Client) do(ctx context.Context, req *http.Request, respData interface{}) (*http.Response, error) {
+func (c *Client) do(ctx context.Context, req *http.Request, respData interface{}) (resp *http.Response, err error) {
+
+	if c.Limiter != nil {
+		start := time.Now()
+		err := c.Limiter.Wait(ctx)
+		if c.Metrics != nil {
+			c.Metrics.ObserveRateLimitWait(memberclicks.MetricsClientClassic, time.Since(start))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, span := tracing.StartRequest(ctx, c.TracerProvider, "memberclicks.classic."+tracing.Operation(ctx, "Do"), req, tracing.OrgID.String(c.OrganizationID))
+	var bodyBytes []byte
+	start := time.Now()
+	defer func() {
+		d := time.Since(start)
+		httplog.Log(ctx, c.Logger, req, resp, bodyBytes, err, d, c.RedactAttributes...)
+		tracing.End(span, 0, err)
+		if c.Metrics != nil {
+			statusCode := 0
+			if resp != nil {
+				statusCode = resp.StatusCode
+			}
+			c.Metrics.ObserveRequest(memberclicks.MetricsClientClassic, req, statusCode, d, len(bodyBytes))
+		}
+	}()
 
-	var err error
-	client := c.getClient(ctx)
-	resp, err := client.Do(req)
+	client, cancel := c.getClient(ctx)
+	defer cancel()
+	resp, err = client.Do(req.WithContext(ctx))
 	if err != nil {
 		return resp, err
 	}
+	tracing.SetStatus(ctx, resp.StatusCode)
 
-	// If not 200, then return the status text.
-	if resp.StatusCode >= 400 {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return resp, err
-		}
-		return resp, fmt.Errorf("error: %s", string(bodyBytes))
+	defer resp.Body.Close()
+	bodyBytes, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp, err
 	}
+	resp.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
 
-	if true {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return resp, err
-		}
-		resp.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
-		fmt.Printf("%s\n", bodyBytes)
+	// If not 200, then return a *memberclicks.APIError.
+	if resp.StatusCode >= 400 {
+		return resp, memberclicks.NewAPIError(resp, bodyBytes)
 	}
 
 	switch resp.Header.Get("Content-Type") {
@@ -207,17 +290,4 @@ func (c *Client) do(ctx context.Context, req *http.Request, respData interface{}
 }
 
 // ErrorResponse is a MemberClicks classic error response
-type ErrorResponse struct {
-	Timestamp      int64             `json:"timestamp"`
-	Status         int               `json:"status"`
-	Error          string            `json:"error"`
-	Message        string            `json:"message"`
-	MessageDetails []string          `json:"messageDetails"` // not sure what format this is
-	Path           string            `json:"path"`
-	Parameters     map[string]string // not sure what format this is, probably string
-}
-
-// Time returns the time of the error response
-func (e *ErrorResponse) Time() time.Time {
-	return time.Unix(e.Timestamp, 0)
-}
+type ErrorResponse = memberclicks.ErrorResponse
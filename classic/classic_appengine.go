@@ -10,10 +10,14 @@ import (
 	"google.golang.org/appengine/urlfetch"
 )
 
-func (c *Client) getClient(ctx context.Context) *http.Client {
+func (c *Client) getClient(ctx context.Context) (*http.Client, context.CancelFunc) {
 	if c.HttpClient != nil {
-		return c.HttpClient
+		return c.HttpClient, func() {}
 	}
-	ctx, _ = context.WithTimeout(ctx, 15*time.Second)
-	return urlfetch.Client(ctx)
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return urlfetch.Client(ctx), cancel
 }
@@ -6,11 +6,17 @@ import (
 	"net/http"
 
 	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks"
 )
 
-func (c *Client) getClient(ctx context.Context) *http.Client {
+func (c *Client) getClient(ctx context.Context) (*http.Client, context.CancelFunc) {
 	if c.HttpClient != nil {
-		return c.HttpClient
+		return c.HttpClient, func() {}
+	}
+	t := c.Transport
+	if t == nil {
+		t = memberclicks.DefaultTransport
 	}
-	return &http.Client{}
+	return &http.Client{Timeout: c.Timeout, Transport: t}, func() {}
 }
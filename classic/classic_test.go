@@ -1,12 +1,17 @@
 package classic
 
 import (
+	"errors"
+	"net/http"
 	"net/url"
 	"os"
 	"testing"
+	"time"
 
 	"golang.org/x/net/context"
 
+	"github.com/bradberger/go-memberclicks"
+	"github.com/bradberger/go-memberclicks/classic/classictest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,11 +22,30 @@ var (
 	password = os.Getenv("MEMBERCLICKS_PASSWORD")
 
 	networkTests = false
+
+	// fake is the fake server the tests use if there is no MemberClicks organization in the environment
+	fake *classictest.Server
 )
 
+func init() {
+	if orgID == "" {
+		fake = classictest.NewServer(nil)
+		orgID, apiKey = "test", classictest.DefaultAPIKey
+		username, password = classictest.DefaultUsername, classictest.DefaultPassword
+	}
+}
+
+// newClient is New, but for the fake server if there is one
+func newClient(ctx context.Context, orgID, password string) (*Client, error) {
+	if fake == nil {
+		return New(ctx, orgID, apiKey, username, password)
+	}
+	return New(ctx, orgID, apiKey, username, password, WithBaseURL(fake.URL))
+}
+
 func TestNew(t *testing.T) {
 	ctx := context.Background()
-	a, err := New(ctx, orgID, apiKey, username, password)
+	a, err := newClient(ctx, orgID, password)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -32,14 +56,19 @@ func TestNew(t *testing.T) {
 
 func TestNewClassicErr(t *testing.T) {
 	ctx := context.Background()
-	a, err := New(ctx, "foobar", apiKey, username, password)
+	// The fake server has no other organizations, so a wrong password is used instead
+	pw := password
+	if fake != nil {
+		pw = "wrong"
+	}
+	a, err := newClient(ctx, "foobar", pw)
 	assert.NotNil(t, a)
-	assert.EqualError(t, err, "error: 401 Unauthorized")
+	assert.True(t, errors.Is(err, memberclicks.ErrUnauthorized))
 }
 
 func TestGetUsers(t *testing.T) {
 	ctx := context.Background()
-	a, err := New(ctx, orgID, apiKey, username, password)
+	a, err := newClient(ctx, orgID, password)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -70,3 +99,104 @@ func TestAPIGetEndpoint(t *testing.T) {
 	a := &Client{OrganizationID: "demo"}
 	assert.Equal(t, "https://demo.memberclicks.net", a.getEndpoint())
 }
+
+func TestAPIBaseURL(t *testing.T) {
+	a := &Client{OrganizationID: "demo", BaseURL: "http://localhost:8080/"}
+	assert.Equal(t, "http://localhost:8080", a.getEndpoint())
+	assert.Equal(t, "http://localhost:8080/foo/bar", a.makeURL("/foo/bar"))
+	assert.Equal(t, "http://localhost:8080/foo/bar", a.makeURL("http://localhost:8080/foo/bar"))
+	assert.Equal(t, "http://localhost:8080/foo/bar", a.makeURL("https://demo.memberclicks.net/foo/bar"))
+}
+
+func TestFakeXML(t *testing.T) {
+	if fake == nil {
+		t.Skip("not using the fake server")
+	}
+	ctx := context.Background()
+	a, err := newClient(ctx, orgID, password)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	req, err := a.NewRequest("GET", "/services/user/"+classictest.DefaultUserID, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	req.Header.Set("Accept", "application/xml")
+	var p Profile
+	resp, err := a.do(ctx, req, &p)
+	assert.NoError(t, err)
+	assert.Equal(t, "application/xml", resp.Header.Get("Content-Type"))
+	assert.Equal(t, classictest.DefaultUserID, p.UserID)
+	assert.True(t, p.Active)
+	assert.Equal(t, "member1@example.com", p.Get("email"))
+}
+
+func TestFakeErrors(t *testing.T) {
+	if fake == nil {
+		t.Skip("not using the fake server")
+	}
+	ctx := context.Background()
+	a, err := newClient(ctx, orgID, password)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = a.User(ctx, "1")
+	assert.True(t, errors.Is(err, memberclicks.ErrNotFound))
+	var apiErr *memberclicks.APIError
+	if assert.True(t, errors.As(err, &apiErr)) && assert.NotNil(t, apiErr.Response) {
+		assert.Equal(t, "User not found", apiErr.Response.Message)
+		assert.Equal(t, "/services/user/1", apiErr.Response.Path)
+	}
+
+	fake.ExpireTokens()
+	_, err = a.Users(ctx, nil)
+	assert.True(t, errors.Is(err, memberclicks.ErrUnauthorized))
+}
+
+func TestNewOptions(t *testing.T) {
+	ctx := context.Background()
+	_, err := New(ctx, "", apiKey, username, password)
+	assert.Equal(t, memberclicks.ErrNoOrgID, err)
+	_, err = New(ctx, "demo", apiKey, username, password, WithBaseURL("/relative"))
+	assert.Error(t, err)
+
+	hc := &http.Client{}
+	a, err := New(ctx, "demo", apiKey, username, password, WithoutAuth(), WithHTTPClient(hc),
+		WithTimeout(time.Second), WithBaseURL("http://localhost:8080"), WithUserAgent("test/1.0"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, hc, a.HttpClient)
+	assert.Equal(t, time.Second, a.Timeout)
+	assert.Equal(t, "http://localhost:8080", a.BaseURL)
+	assert.Empty(t, a.token)
+
+	req, err := a.NewRequest("GET", "/services/user", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "test/1.0", req.UserAgent())
+
+	a, err = New(ctx, "demo", apiKey, username, password, WithToken("abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", a.token)
+}
+
+func TestNewFromConfig(t *testing.T) {
+	ctx := context.Background()
+	c := &memberclicks.Config{OrgID: orgID, APIKey: apiKey, Username: username, Timeout: memberclicks.Duration(time.Second)}
+	_, err := NewFromConfig(ctx, c)
+	assert.True(t, errors.Is(err, memberclicks.ErrMissingSetting))
+	assert.Contains(t, err.Error(), "MEMBERCLICKS_PASSWORD")
+
+	c.Password = password
+	if fake != nil {
+		c.BaseURL = fake.URL
+	}
+	a, err := NewFromConfig(ctx, c)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, time.Second, a.Timeout)
+	assert.NotEmpty(t, a.token)
+}
@@ -0,0 +1,342 @@
+// Package classictest provides an in-process fake of the MemberClicks Classic API for
+// testing classic clients without credentials or network access.
+package classictest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default credentials of the fixtures returned by DefaultFixtures
+const (
+	DefaultAPIKey   = "test-api-key"
+	DefaultUsername = "jdoe"
+	DefaultPassword = "password"
+	DefaultUserID   = "12345678"
+	DefaultGroupID  = "1234"
+)
+
+// Fixtures is the data the fake server is seeded with
+type Fixtures struct {
+	// APIKey is the API key which has to be sent to /services/auth
+	APIKey string
+	Users  []User
+}
+
+// User is a classic user. Users with a UserName and Password can sign in.
+type User struct {
+	UserID      string
+	GroupID     string
+	ContactName string
+	UserName    string
+	Password    string
+	Active      bool
+	Validated   bool
+	Deleted     bool
+	Attributes  []Attribute
+}
+
+// Attribute is a profile attribute of a user
+type Attribute struct {
+	AttID      string
+	AttTypeID  int
+	AttName    string
+	AttData    string
+	LastModify time.Time
+}
+
+// DefaultFixtures returns the DefaultAPIKey and 25 users. The first one signs in with
+// DefaultUsername and DefaultPassword and has DefaultUserID.
+func DefaultFixtures() *Fixtures {
+	f := &Fixtures{APIKey: DefaultAPIKey}
+	modified := time.Date(2010, 10, 21, 16, 46, 31, 0, time.UTC)
+	id, _ := strconv.Atoi(DefaultUserID)
+	for i := 0; i < 25; i++ {
+		userID := strconv.Itoa(id + i)
+		u := User{
+			UserID:      userID,
+			GroupID:     DefaultGroupID,
+			ContactName: fmt.Sprintf("Member %d", i+1),
+			UserName:    fmt.Sprintf("member%d", i+1),
+			Password:    fmt.Sprintf("password%d", i+1),
+			Active:      true,
+			Validated:   true,
+			Attributes: []Attribute{
+				{AttID: "4567", AttTypeID: 1, AttName: "Email", AttData: fmt.Sprintf("member%d@example.com", i+1), LastModify: modified},
+				{AttID: "4568", AttTypeID: 2, AttName: "First Name", AttData: "Member", LastModify: modified},
+				{AttID: "4569", AttTypeID: 3, AttName: "Last Name", AttData: strconv.Itoa(i + 1), LastModify: modified},
+			},
+		}
+		if i == 0 {
+			u.UserName, u.Password = DefaultUsername, DefaultPassword
+		}
+		f.Users = append(f.Users, u)
+	}
+	return f
+}
+
+// Server is a fake MemberClicks Classic server. Point a client at it with its URL as the
+// client's BaseURL. Responses are JSON if the request accepts application/json, and XML otherwise.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	fixtures *Fixtures
+	tokens   map[string]*User
+	requests map[string]int
+}
+
+// NewServer starts a fake server seeded with the fixtures. If f is nil, DefaultFixtures are used.
+func NewServer(f *Fixtures) *Server {
+	if f == nil {
+		f = DefaultFixtures()
+	}
+	s := &Server{
+		fixtures: f,
+		tokens:   map[string]*User{},
+		requests: map[string]int{},
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// ExpireTokens invalidates all tokens issued so far
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = map[string]*User{}
+}
+
+// Requests returns how many requests were received for the method and path, for example "GET /services/user"
+func (s *Server) Requests(methodPath string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[methodPath]
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.Method+" "+r.URL.Path]++
+	s.mu.Unlock()
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	if path == "/services/auth" && r.Method == "POST" {
+		s.auth(w, r)
+		return
+	}
+	if !strings.HasPrefix(path, "/services/") {
+		s.error(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	if !s.authenticate(r) {
+		s.error(w, r, http.StatusUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	switch {
+	case path == "/services/user" && r.Method == "GET":
+		s.users(w, r)
+	case strings.HasPrefix(path, "/services/user/") && r.Method == "GET":
+		s.user(w, r, strings.TrimPrefix(path, "/services/user/"))
+	default:
+		s.error(w, r, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (s *Server) auth(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.PostFormValue("apiKey") != s.fixtures.APIKey {
+		s.error(w, r, http.StatusUnauthorized, "Invalid API key")
+		return
+	}
+	var u *User
+	for i := range s.fixtures.Users {
+		if s.fixtures.Users[i].UserName != "" && s.fixtures.Users[i].UserName == r.PostFormValue("username") {
+			u = &s.fixtures.Users[i]
+		}
+	}
+	if u == nil || u.Password != r.PostFormValue("password") {
+		s.error(w, r, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	token := randomID()
+	s.tokens[token] = u
+	resp := authResponse{
+		UserID:      u.UserID,
+		GroupID:     u.GroupID,
+		OrgID:       "test",
+		ContactName: u.ContactName,
+		UserName:    u.UserName,
+		Active:      strconv.FormatBool(u.Active),
+		Validated:   strconv.FormatBool(u.Validated),
+		Deleted:     strconv.FormatBool(u.Deleted),
+		NoMassEmail: "false",
+		Token:       token,
+	}
+	write(w, r, http.StatusOK, &resp)
+}
+
+// authenticate reports whether the Authorization header is a token issued by /services/auth.
+// Classic tokens are sent as is, without a scheme like Bearer.
+func (s *Server) authenticate(r *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.tokens[r.Header.Get("Authorization")]
+	return ok
+}
+
+func (s *Server) users(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	pageNum, pageSize := queryInt(q.Get("pageNum"), 1), queryInt(q.Get("pageSize"), 25)
+	if pageNum < 1 || pageSize < 1 {
+		s.error(w, r, http.StatusBadRequest, "Invalid pageNum or pageSize")
+		return
+	}
+
+	s.mu.Lock()
+	users := s.fixtures.Users
+	s.mu.Unlock()
+
+	list := userList{Users: []user{}}
+	for i := (pageNum - 1) * pageSize; i < len(users) && i < pageNum*pageSize; i++ {
+		// Lists have the user summary only, without attributes
+		list.Users = append(list.Users, newUser(users[i], false))
+	}
+	write(w, r, http.StatusOK, &list)
+}
+
+func (s *Server) user(w http.ResponseWriter, r *http.Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.fixtures.Users {
+		if s.fixtures.Users[i].UserID == userID {
+			u := newUser(s.fixtures.Users[i], true)
+			write(w, r, http.StatusOK, &u)
+			return
+		}
+	}
+	s.error(w, r, http.StatusNotFound, "User not found")
+}
+
+// error writes an error response in the format of memberclicks.ErrorResponse
+func (s *Server) error(w http.ResponseWriter, r *http.Request, status int, message string) {
+	write(w, r, status, &errorResponse{
+		Timestamp: time.Now().Unix(),
+		Status:    status,
+		Error:     http.StatusText(status),
+		Message:   message,
+		Path:      r.URL.Path,
+	})
+}
+
+// write encodes v as JSON if the request accepts it, and as XML otherwise
+func write(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(v)
+}
+
+type authResponse struct {
+	XMLName     xml.Name `json:"-" xml:"authResponse"`
+	UserID      string   `json:"userId" xml:"userId"`
+	GroupID     string   `json:"groupId" xml:"groupId"`
+	OrgID       string   `json:"orgId" xml:"orgId"`
+	ContactName string   `json:"contactName" xml:"contactName"`
+	UserName    string   `json:"userName" xml:"userName"`
+	Active      string   `json:"active" xml:"active"`
+	Validated   string   `json:"validated" xml:"validated"`
+	Deleted     string   `json:"deleted" xml:"deleted"`
+	NoMassEmail string   `json:"noMassEmail" xml:"noMassEmail"`
+	Token       string   `json:"token" xml:"token"`
+}
+
+type userList struct {
+	XMLName xml.Name `json:"-" xml:"userList"`
+	Users   []user   `json:"user" xml:"user"`
+}
+
+// user is the wire format of a User, the booleans are strings in JSON
+type user struct {
+	XMLName     xml.Name    `json:"-" xml:"user"`
+	UserID      string      `json:"userId" xml:"userId"`
+	GroupID     string      `json:"groupId" xml:"groupId"`
+	ContactName string      `json:"contactName" xml:"contactName"`
+	Active      string      `json:"active" xml:"active"`
+	Validated   string      `json:"validated" xml:"validated"`
+	Deleted     string      `json:"deleted" xml:"deleted"`
+	Attributes  []attribute `json:"attribute,omitempty" xml:"attribute"`
+}
+
+type attribute struct {
+	UserID     string    `json:"userId" xml:"userId"`
+	AttID      string    `json:"attId" xml:"attId"`
+	AttTypeID  int       `json:"attTypeId" xml:"attTypeId"`
+	AttName    string    `json:"attName" xml:"attName"`
+	AttData    string    `json:"attData" xml:"attData"`
+	LastModify time.Time `json:"lastModifiy" xml:"lastModify"`
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `json:"-" xml:"error"`
+	Timestamp int64    `json:"timestamp" xml:"timestamp"`
+	Status    int      `json:"status" xml:"status"`
+	Error     string   `json:"error" xml:"error"`
+	Message   string   `json:"message" xml:"message"`
+	Path      string   `json:"path" xml:"path"`
+}
+
+func newUser(u User, attributes bool) user {
+	res := user{
+		UserID:      u.UserID,
+		GroupID:     u.GroupID,
+		ContactName: u.ContactName,
+		Active:      strconv.FormatBool(u.Active),
+		Validated:   strconv.FormatBool(u.Validated),
+		Deleted:     strconv.FormatBool(u.Deleted),
+	}
+	if attributes {
+		for _, a := range u.Attributes {
+			res.Attributes = append(res.Attributes, attribute{
+				UserID:     u.UserID,
+				AttID:      a.AttID,
+				AttTypeID:  a.AttTypeID,
+				AttName:    a.AttName,
+				AttData:    a.AttData,
+				LastModify: a.LastModify,
+			})
+		}
+	}
+	return res
+}
+
+func queryInt(s string, def int) int {
+	if i, err := strconv.Atoi(s); err == nil {
+		return i
+	}
+	return def
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,48 @@
+package classic
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks"
+)
+
+// NewFromConfig creates a new Client with the classic API settings of the configuration,
+// like New. The options are applied after the ones of the configuration.
+func NewFromConfig(ctx context.Context, c *memberclicks.Config, opts ...Option) (*Client, error) {
+	if err := c.Require("orgId", "apiKey", "username", "password"); err != nil {
+		return nil, err
+	}
+	var all []Option
+	if c.BaseURL != "" {
+		all = append(all, WithBaseURL(c.BaseURL))
+	}
+	if c.Timeout != 0 {
+		all = append(all, WithTimeout(time.Duration(c.Timeout)))
+	}
+	if c.UserAgent != "" {
+		all = append(all, WithUserAgent(c.UserAgent))
+	}
+	return New(ctx, c.OrgID, c.APIKey, c.Username, c.Password, append(all, opts...)...)
+}
+
+// NewFromEnv creates a new Client with the configuration of the environment variables,
+// see memberclicks.ConfigFromEnv
+func NewFromEnv(ctx context.Context, opts ...Option) (*Client, error) {
+	c, err := memberclicks.ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewFromConfig(ctx, c, opts...)
+}
+
+// NewFromFile creates a new Client with the configuration of the file at path, see
+// memberclicks.LoadConfig
+func NewFromFile(ctx context.Context, path string, opts ...Option) (*Client, error) {
+	c, err := memberclicks.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromConfig(ctx, c, opts...)
+}
@@ -0,0 +1,85 @@
+package classic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Option configures a Client created with New
+type Option func(c *Client) error
+
+// WithHTTPClient sets the HTTP client requests are sent with
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) error {
+		if hc == nil {
+			return errors.New("classic: HTTP client is nil")
+		}
+		c.HttpClient = hc
+		return nil
+	}
+}
+
+// WithTransport sets the transport requests are sent with, instead of the shared
+// memberclicks DefaultTransport
+func WithTransport(t http.RoundTripper) Option {
+	return func(c *Client) error {
+		c.Transport = t
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of requests
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("classic: invalid timeout %v", d)
+		}
+		c.Timeout = d
+		return nil
+	}
+}
+
+// WithBaseURL sends requests to baseURL instead of the organization's own URL
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) error {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return fmt.Errorf("classic: invalid base URL: %v", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("classic: base URL %q is not an absolute HTTP URL", baseURL)
+		}
+		c.BaseURL = baseURL
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header of requests
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) error {
+		c.UserAgent = userAgent
+		return nil
+	}
+}
+
+// WithToken uses a token from an earlier Auth instead of authenticating in New
+func WithToken(token string) Option {
+	return func(c *Client) error {
+		if token == "" {
+			return errors.New("classic: token is blank")
+		}
+		c.token = token
+		return nil
+	}
+}
+
+// WithoutAuth skips the authentication in New. Call Auth before making requests.
+func WithoutAuth() Option {
+	return func(c *Client) error {
+		c.skipAuth = true
+		return nil
+	}
+}
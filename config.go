@@ -0,0 +1,249 @@
+package memberclicks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables read by ConfigFromEnv. The secrets can also be read from files, by
+// setting the variable with a _FILE suffix to the path of the file instead, for example
+// MEMBERCLICKS_CLIENT_SECRET_FILE=/run/secrets/memberclicks_client_secret.
+const (
+	EnvOrgID        = "MEMBERCLICKS_ORG_ID"
+	EnvClientID     = "MEMBERCLICKS_CLIENT_ID"
+	EnvClientSecret = "MEMBERCLICKS_CLIENT_SECRET"
+	EnvAPIKey       = "MEMBERCLICKS_API_KEY"
+	EnvUsername     = "MEMBERCLICKS_USERNAME"
+	EnvPassword     = "MEMBERCLICKS_PASSWORD"
+	EnvBaseURL      = "MEMBERCLICKS_BASE_URL"
+	EnvTimeout      = "MEMBERCLICKS_TIMEOUT"
+	EnvScopes       = "MEMBERCLICKS_SCOPES"
+	EnvUserAgent    = "MEMBERCLICKS_USER_AGENT"
+)
+
+// ErrMissingSetting is returned for configurations without a required setting. The
+// error names the setting by its config file key, and its environment variable.
+var ErrMissingSetting = errors.New("memberclicks: missing setting")
+
+// Config is the configuration of the API and classic clients, read from the environment
+// with ConfigFromEnv or from a file with LoadConfig
+type Config struct {
+	OrgID string `json:"orgId" yaml:"orgId" toml:"orgId"`
+
+	ClientID     string `json:"clientId" yaml:"clientId" toml:"clientId"`
+	ClientSecret string `json:"clientSecret" yaml:"clientSecret" toml:"clientSecret"`
+
+	// APIKey, Username and Password are the classic API credentials
+	APIKey   string `json:"apiKey,omitempty" yaml:"apiKey,omitempty" toml:"apiKey,omitempty"`
+	Username string `json:"username,omitempty" yaml:"username,omitempty" toml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty" toml:"password,omitempty"`
+
+	// ClientSecretFile, APIKeyFile and PasswordFile are paths of files to read the secrets
+	// from instead, like Docker and Kubernetes secrets
+	ClientSecretFile string `json:"clientSecretFile,omitempty" yaml:"clientSecretFile,omitempty" toml:"clientSecretFile,omitempty"`
+	APIKeyFile       string `json:"apiKeyFile,omitempty" yaml:"apiKeyFile,omitempty" toml:"apiKeyFile,omitempty"`
+	PasswordFile     string `json:"passwordFile,omitempty" yaml:"passwordFile,omitempty" toml:"passwordFile,omitempty"`
+
+	BaseURL   string   `json:"baseUrl,omitempty" yaml:"baseUrl,omitempty" toml:"baseUrl,omitempty"`
+	Timeout   Duration `json:"timeout,omitempty" yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+	Scopes    []string `json:"scopes,omitempty" yaml:"scopes,omitempty" toml:"scopes,omitempty"`
+	UserAgent string   `json:"userAgent,omitempty" yaml:"userAgent,omitempty" toml:"userAgent,omitempty"`
+}
+
+// Duration is a time.Duration written like "30s" in config files
+type Duration time.Duration
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// ConfigFromEnv returns the configuration of the environment variables
+func ConfigFromEnv() (*Config, error) {
+	c := &Config{
+		OrgID:            os.Getenv(EnvOrgID),
+		ClientID:         os.Getenv(EnvClientID),
+		ClientSecret:     os.Getenv(EnvClientSecret),
+		ClientSecretFile: os.Getenv(EnvClientSecret + "_FILE"),
+		APIKey:           os.Getenv(EnvAPIKey),
+		APIKeyFile:       os.Getenv(EnvAPIKey + "_FILE"),
+		Username:         os.Getenv(EnvUsername),
+		Password:         os.Getenv(EnvPassword),
+		PasswordFile:     os.Getenv(EnvPassword + "_FILE"),
+		BaseURL:          os.Getenv(EnvBaseURL),
+		Scopes:           strings.FieldsFunc(os.Getenv(EnvScopes), isScopeSep),
+		UserAgent:        os.Getenv(EnvUserAgent),
+	}
+	if s := os.Getenv(EnvTimeout); s != "" {
+		if err := c.Timeout.UnmarshalText([]byte(s)); err != nil {
+			return nil, fmt.Errorf("memberclicks: invalid %s: %v", EnvTimeout, err)
+		}
+	}
+	if err := c.ReadSecretFiles(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func isScopeSep(r rune) bool {
+	return r == ',' || r == ' '
+}
+
+var (
+	configFormatsMu sync.RWMutex
+	configFormats   = map[string]func([]byte, interface{}) error{".json": json.Unmarshal}
+)
+
+// RegisterConfigFormat makes LoadConfig decode files with the extension, like ".yaml", with
+// unmarshal. JSON is built in, the yamlconfig and tomlconfig packages register YAML and TOML
+// when they're imported.
+func RegisterConfigFormat(ext string, unmarshal func([]byte, interface{}) error) {
+	configFormatsMu.Lock()
+	defer configFormatsMu.Unlock()
+	configFormats[strings.ToLower(ext)] = unmarshal
+}
+
+// UnmarshalConfigFile decodes the config file at path into v, in the format registered
+// for its extension
+func UnmarshalConfigFile(path string, v interface{}) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	configFormatsMu.RLock()
+	unmarshal, ok := configFormats[ext]
+	configFormatsMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("memberclicks: unknown config file format %q", ext)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := unmarshal(b, v); err != nil {
+		return fmt.Errorf("memberclicks: invalid config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// LoadConfig returns the configuration of the config file at path. The format is chosen
+// by the extension of the file, see RegisterConfigFormat.
+func LoadConfig(path string) (*Config, error) {
+	c := &Config{}
+	if err := UnmarshalConfigFile(path, c); err != nil {
+		return nil, err
+	}
+	if err := c.ReadSecretFiles(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ReadSecretFiles reads the secrets which are set as file paths, like ClientSecretFile.
+// The paths are cleared once they're read, so reading them again does nothing.
+// ConfigFromEnv and LoadConfig read them already.
+func (c *Config) ReadSecretFiles() error {
+	for _, s := range []struct {
+		value, path *string
+		name        string
+	}{
+		{&c.ClientSecret, &c.ClientSecretFile, "clientSecret"},
+		{&c.APIKey, &c.APIKeyFile, "apiKey"},
+		{&c.Password, &c.PasswordFile, "password"},
+	} {
+		if *s.path == "" {
+			continue
+		}
+		if *s.value != "" {
+			return fmt.Errorf("memberclicks: both %s and %sFile are set", s.name, s.name)
+		}
+		b, err := ioutil.ReadFile(*s.path)
+		if err != nil {
+			return fmt.Errorf("memberclicks: could not read %sFile: %v", s.name, err)
+		}
+		*s.value, *s.path = strings.TrimRight(string(b), "\r\n"), ""
+	}
+	return nil
+}
+
+// Require checks that the settings are set, by their names in config files like
+// "clientSecret"
+func (c *Config) Require(names ...string) error {
+	settings := map[string]struct{ value, env string }{
+		"orgId":        {c.OrgID, EnvOrgID},
+		"clientId":     {c.ClientID, EnvClientID},
+		"clientSecret": {c.ClientSecret, EnvClientSecret},
+		"apiKey":       {c.APIKey, EnvAPIKey},
+		"username":     {c.Username, EnvUsername},
+		"password":     {c.Password, EnvPassword},
+	}
+	for _, name := range names {
+		s, ok := settings[name]
+		if !ok {
+			return fmt.Errorf("memberclicks: unknown setting %s", name)
+		}
+		if strings.TrimSpace(s.value) == "" {
+			return fmt.Errorf("%w %s, or environment variable %s", ErrMissingSetting, name, s.env)
+		}
+	}
+	return nil
+}
+
+// Options returns the options of the optional settings
+func (c *Config) Options() []Option {
+	var opts []Option
+	if c.BaseURL != "" {
+		opts = append(opts, WithBaseURL(c.BaseURL))
+	}
+	if c.Timeout != 0 {
+		opts = append(opts, WithTimeout(time.Duration(c.Timeout)))
+	}
+	if len(c.Scopes) > 0 {
+		opts = append(opts, WithScopes(c.Scopes...))
+	}
+	if c.UserAgent != "" {
+		opts = append(opts, WithUserAgent(c.UserAgent))
+	}
+	return opts
+}
+
+// NewFromConfig creates a new API client with the configuration. The options are applied
+// after the ones of the configuration.
+func NewFromConfig(c *Config, opts ...Option) (*API, error) {
+	if err := c.Require("orgId", "clientId", "clientSecret"); err != nil {
+		return nil, err
+	}
+	return New(c.OrgID, c.ClientID, c.ClientSecret, append(c.Options(), opts...)...)
+}
+
+// NewFromEnv creates a new API client with the configuration of the environment variables
+func NewFromEnv(opts ...Option) (*API, error) {
+	c, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewFromConfig(c, opts...)
+}
+
+// NewFromFile creates a new API client with the configuration of the file at path, see LoadConfig
+func NewFromFile(path string, opts ...Option) (*API, error) {
+	c, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromConfig(c, opts...)
+}
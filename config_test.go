@@ -0,0 +1,119 @@
+package memberclicks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setenv sets the environment variables for the duration of the test
+func setenv(t *testing.T, env map[string]string) {
+	for k, v := range env {
+		old, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	secret := writeFile(t, "secret", "s3cret\n")
+	setenv(t, map[string]string{
+		EnvOrgID:                  "demo",
+		EnvClientID:               "id",
+		EnvClientSecret:           "",
+		EnvClientSecret + "_FILE": secret,
+		EnvBaseURL:                "http://localhost:8080",
+		EnvTimeout:                "5s",
+		EnvScopes:                 "read, write",
+		EnvUserAgent:              "test/1.0",
+	})
+
+	c, err := ConfigFromEnv()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "s3cret", c.ClientSecret)
+	assert.Equal(t, []string{"read", "write"}, c.Scopes)
+	assert.Equal(t, Duration(5*time.Second), c.Timeout)
+
+	a, err := NewFromEnv()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "s3cret", a.clientSecret)
+	assert.Equal(t, "http://localhost:8080", a.BaseURL)
+	assert.Equal(t, 5*time.Second, a.Timeout)
+	assert.Equal(t, "read write", a.scope())
+	assert.Equal(t, "test/1.0", a.UserAgent)
+
+	setenv(t, map[string]string{EnvClientSecret: "other"})
+	_, err = ConfigFromEnv()
+	assert.Error(t, err, "the secret and its file are both set")
+
+	setenv(t, map[string]string{EnvClientSecret: "", EnvClientSecret + "_FILE": "", EnvClientID: ""})
+	_, err = NewFromEnv()
+	assert.True(t, errors.Is(err, ErrMissingSetting))
+	assert.Contains(t, err.Error(), "MEMBERCLICKS_CLIENT_ID")
+
+	setenv(t, map[string]string{EnvTimeout: "5"})
+	_, err = ConfigFromEnv()
+	assert.Error(t, err)
+}
+
+func TestLoadConfig(t *testing.T) {
+	password := writeFile(t, "password", "pw")
+	c, err := LoadConfig(writeFile(t, "config.json", `{"orgId": "demo", "clientId": "id", "clientSecret": "secret", "timeout": "10s", "scopes": ["read"], "passwordFile": "`+password+`"}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, &Config{
+			OrgID:        "demo",
+			ClientID:     "id",
+			ClientSecret: "secret",
+			Password:     "pw",
+			Timeout:      Duration(10 * time.Second),
+			Scopes:       []string{"read"},
+		}, c)
+	}
+
+	_, err = LoadConfig(writeFile(t, "config.yaml", "orgId: demo"))
+	assert.Error(t, err, "YAML isn't registered without the yamlconfig package")
+	_, err = LoadConfig(writeFile(t, "bad.json", "{"))
+	assert.Error(t, err)
+	_, err = LoadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+
+	RegisterConfigFormat(".CONF", func(b []byte, v interface{}) error {
+		return json.Unmarshal(bytes.TrimPrefix(b, []byte("#!conf\n")), v)
+	})
+	c, err = LoadConfig(writeFile(t, "config.conf", "#!conf\n{\"orgId\": \"demo\"}"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "demo", c.OrgID)
+	}
+
+	a, err := NewFromFile(writeFile(t, "config.json", `{"orgId": "demo", "clientId": "id", "clientSecret": "secret"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, ScopeRead, a.scope())
+	_, err = NewFromFile(writeFile(t, "config.json", `{"orgId": "demo"}`))
+	assert.True(t, errors.Is(err, ErrMissingSetting))
+	assert.Contains(t, err.Error(), "missing setting clientId, or environment variable MEMBERCLICKS_CLIENT_ID")
+}
@@ -21,8 +21,9 @@ type countryResponse struct {
 
 // Countries returns a list of countries
 func (a *API) Countries(ctx context.Context) (Countries, error) {
+	ctx = withOperation(ctx, "Countries")
 	var res countryResponse
-	if err := a.Get(ctx, "/api/v1/country", &res); err != nil {
+	if err := a.getCached(ctx, CountriesPath, &res); err != nil {
 		return nil, err
 	}
 	return res.Countries, nil
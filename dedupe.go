@@ -0,0 +1,59 @@
+package memberclicks
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// WithDedupe makes identical concurrent GET requests share one request, see the API Dedupe field
+func WithDedupe() Option {
+	return func(a *API) error {
+		a.Dedupe = true
+		return nil
+	}
+}
+
+// sendAttempt sends one attempt of the request through the middlewares. With Dedupe, identical
+// GETs in flight at the same time share one attempt, and every caller decodes its own
+// copy of the response, and gets its own copy of its metadata and API error. The shared
+// attempt runs detached from the callers, so that one of them giving up doesn't fail the
+// others, and each caller stops waiting when its ctx is done.
+func (a *API) sendAttempt(ctx context.Context, req *http.Request, result interface{}) error {
+	h := a.handler()
+	if _, stream := result.(streamDecoder); !a.Dedupe || req.Method != "GET" || stream {
+		return h(ctx, req, result)
+	}
+
+	// The credential is part of the key, other tokens may see other data
+	key := req.URL.String() + "\n" + req.Header.Get("Authorization")
+	ch := a.dedupeGroup.DoChan(key, func() (interface{}, error) {
+		res := &sharedResponse{}
+		ctx, cancel := a.detach(WithResponseMeta(ctx, &res.meta))
+		defer cancel()
+		var body json.RawMessage
+		err := h(ctx, req.Clone(ctx), &body)
+		res.body = body
+		return res, err
+	})
+	select {
+	case r := <-ch:
+		res := r.Val.(*sharedResponse)
+		if meta := responseMetaFrom(ctx); meta != nil {
+			meta.recordShared(&res.meta)
+		}
+		if apiErr, ok := r.Err.(*APIError); ok {
+			return apiErr.clone()
+		}
+		if r.Err != nil {
+			return r.Err
+		}
+		if len(res.body) > 0 && result != nil {
+			return json.Unmarshal(res.body, result)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,140 @@
+package memberclicks
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks/memberclickstest"
+)
+
+func TestDedupe(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+	a, err := New("test", memberclickstest.DefaultClientID, memberclickstest.DefaultClientSecret, WithBaseURL(srv.URL), WithDedupe())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, a.Auth(ctx))
+
+	srv.Inject(memberclickstest.Fault{Path: "/api/v1/profile/", Latency: 50 * time.Millisecond})
+	profiles := make([]*Profile, 10)
+	var wg sync.WaitGroup
+	for i := range profiles {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p, err := a.Profile(ctx, "1002583186")
+			assert.NoError(t, err)
+			profiles[i] = p
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, srv.Requests("GET /api/v1/profile/1002583186"))
+
+	// Every caller has its own copy
+	profiles[0].Set("[Contact Name]", "changed")
+	for _, p := range profiles[1:] {
+		var name string
+		assert.NoError(t, p.Get("[Contact Name]", &name))
+		assert.Equal(t, "Member 1", name)
+	}
+
+	// Requests with other credentials aren't shared
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		tok, err := a.OwnerPassword(ctx, memberclickstest.DefaultUsername, memberclickstest.DefaultPassword)
+		if !assert.NoError(t, err) {
+			return
+		}
+		tokens = append(tokens, tok.AccessToken)
+	}
+	wg.Add(len(tokens))
+	for _, tok := range tokens {
+		go func(tok string) {
+			defer wg.Done()
+			_, err := a.Me(ctx, tok)
+			assert.NoError(t, err)
+		}(tok)
+	}
+	wg.Wait()
+	assert.Equal(t, 2, srv.Requests("GET /api/v1/profile/me"))
+
+	_, err = a.Profile(ctx, "1")
+	assert.Error(t, err)
+}
+
+func TestDedupeCancel(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+	a, _ := New("test", memberclickstest.DefaultClientID, memberclickstest.DefaultClientSecret, WithBaseURL(srv.URL), WithDedupe())
+	assert.NoError(t, a.Auth(ctx))
+	srv.Inject(memberclickstest.Fault{Path: "/api/v1/profile/", Latency: 100 * time.Millisecond})
+
+	// The first caller gives up, the second one still gets the shared response
+	first, cancel := context.WithCancel(ctx)
+	errc := make(chan error)
+	go func() {
+		_, err := a.Profile(first, "1002583186")
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p, err := a.Profile(ctx, "1002583186")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "1002583186", p.GetID())
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	start := time.Now()
+	cancel()
+	assert.True(t, errors.Is(<-errc, context.Canceled))
+	assert.True(t, time.Since(start) < 50*time.Millisecond, "the canceled caller returns without waiting")
+	<-done
+	assert.Equal(t, 1, srv.Requests("GET /api/v1/profile/1002583186"))
+}
+
+func TestDedupeMeta(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+	a, _ := New("test", memberclickstest.DefaultClientID, memberclickstest.DefaultClientSecret, WithBaseURL(srv.URL), WithDedupe())
+	assert.NoError(t, a.Auth(ctx))
+	srv.Inject(memberclickstest.Fault{Path: "/api/v1/group", StatusCode: http.StatusServiceUnavailable,
+		Header: http.Header{"X-Request-Id": {"abc"}}, Latency: 50 * time.Millisecond})
+
+	metas := make([]ResponseMeta, 3)
+	errs := make([]*APIError, len(metas))
+	var wg sync.WaitGroup
+	for i := range metas {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := a.Get(WithResponseMeta(ctx, &metas[i]), "/api/v1/group", nil)
+			assert.True(t, errors.As(err, &errs[i]))
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, srv.Requests("GET /api/v1/group"))
+	for _, meta := range metas {
+		assert.Equal(t, http.StatusServiceUnavailable, meta.StatusCode)
+		assert.Equal(t, "abc", meta.RequestID)
+		assert.Equal(t, 1, meta.Attempts)
+	}
+
+	// Every caller has its own copy of the error and metadata
+	errs[0].Header.Set("X-Request-Id", "changed")
+	errs[0].Body[0] = 'x'
+	metas[0].Header.Set("X-Request-Id", "changed")
+	for i := 1; i < len(errs); i++ {
+		assert.Equal(t, "abc", errs[i].Header.Get("X-Request-Id"))
+		assert.Equal(t, byte('{'), errs[i].Body[0])
+		assert.Equal(t, "abc", metas[i].Header.Get("X-Request-Id"))
+	}
+}
@@ -0,0 +1,139 @@
+package memberclicks
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Errors which an *APIError can be compared against with errors.Is
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServer       = errors.New("server error")
+)
+
+// ErrorResponse is a MemberClicks error response body
+type ErrorResponse struct {
+	Timestamp      int64             `json:"timestamp"`
+	Status         int               `json:"status"`
+	Error          string            `json:"error"`
+	Message        string            `json:"message"`
+	MessageDetails []string          `json:"messageDetails"` // not sure what format this is
+	Path           string            `json:"path"`
+	Parameters     map[string]string `json:"parameters"` // not sure what format this is, probably string
+}
+
+// Time returns the time of the error response
+func (e *ErrorResponse) Time() time.Time {
+	return time.Unix(e.Timestamp, 0)
+}
+
+// APIError is returned when the MemberClicks servers respond with a 4XX or 5XX status code
+type APIError struct {
+	StatusCode int
+	Status     string
+	Method     string
+	URL        string
+	Header     http.Header
+
+	// Response is the parsed error body, or nil if the body was not a MemberClicks JSON error.
+	Response *ErrorResponse
+
+	// Body is the raw response body
+	Body []byte
+}
+
+// NewAPIError creates an *APIError from the response and the already read response body
+func NewAPIError(resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       body,
+	}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		if resp.Request.URL != nil {
+			e.URL = resp.Request.URL.String()
+		}
+	}
+	var r ErrorResponse
+	if err := json.Unmarshal(body, &r); err == nil && (r.Status != 0 || r.Error != "" || r.Message != "") {
+		e.Response = &r
+	}
+	return e
+}
+
+// clone returns a copy of e, which doesn't share the header, body or parsed response with e
+func (e *APIError) clone() *APIError {
+	c := *e
+	c.Header = e.Header.Clone()
+	c.Body = append([]byte(nil), e.Body...)
+	if e.Response != nil {
+		r := *e.Response
+		r.MessageDetails = append([]string(nil), r.MessageDetails...)
+		if r.Parameters != nil {
+			r.Parameters = make(map[string]string, len(e.Response.Parameters))
+			for k, v := range e.Response.Parameters {
+				r.Parameters[k] = v
+			}
+		}
+		c.Response = &r
+	}
+	return &c
+}
+
+// Error implements the error interface. It prefers the MemberClicks error message,
+// then the raw response body, then the HTTP status text.
+func (e *APIError) Error() string {
+	if e.Response != nil && e.Response.Message != "" {
+		return e.Response.Message
+	}
+	if len(e.Body) > 0 {
+		return string(e.Body)
+	}
+	if e.Status != "" {
+		return e.Status
+	}
+	return http.StatusText(e.StatusCode)
+}
+
+// LogValue implements slog.LogValuer. Only the status code and the parsed MemberClicks
+// error are logged, never the raw body, which may hold tokens or personal data.
+func (e *APIError) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.Int("status", e.StatusCode)}
+	if e.Response != nil {
+		if e.Response.Error != "" {
+			attrs = append(attrs, slog.String("code", e.Response.Error))
+		}
+		if e.Response.Message != "" {
+			attrs = append(attrs, slog.String("message", e.Response.Message))
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Is makes the error comparable to the status code sentinel errors with errors.Is
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package memberclicks
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIError(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://demo.memberclicks.net/api/v1/profile/1", nil)
+	resp := &http.Response{StatusCode: 404, Status: "404 Not Found", Request: req, Header: http.Header{}}
+	body := []byte(`{"timestamp":1507939200,"status":404,"error":"Not Found","message":"Profile not found","messageDetails":["1"],"path":"/api/v1/profile/1"}`)
+
+	err := NewAPIError(resp, body)
+	assert.Equal(t, "GET", err.Method)
+	assert.Equal(t, "https://demo.memberclicks.net/api/v1/profile/1", err.URL)
+	if assert.NotNil(t, err.Response) {
+		assert.Equal(t, "/api/v1/profile/1", err.Response.Path)
+		assert.Equal(t, []string{"1"}, err.Response.MessageDetails)
+		assert.Equal(t, 2017, err.Response.Time().Year())
+	}
+	assert.EqualError(t, err, "Profile not found")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.False(t, errors.Is(err, ErrUnauthorized))
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	resp := &http.Response{StatusCode: 429, Status: "429 Too Many Requests"}
+	assert.EqualError(t, NewAPIError(resp, nil), "429 Too Many Requests")
+	assert.EqualError(t, NewAPIError(resp, []byte("slow down")), "slow down")
+	assert.Nil(t, NewAPIError(resp, []byte("slow down")).Response)
+	assert.True(t, errors.Is(NewAPIError(resp, nil), ErrRateLimited))
+	assert.True(t, errors.Is(NewAPIError(&http.Response{StatusCode: 502}, nil), ErrServer))
+	assert.EqualError(t, NewAPIError(&http.Response{StatusCode: 401}, nil), "Unauthorized")
+}
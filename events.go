@@ -18,8 +18,9 @@ type eventsResponse struct {
 
 // Events returns an event list
 func (a *API) Events(ctx context.Context) (Events, error) {
+	ctx = withOperation(ctx, "Events")
 	var res eventsResponse
-	if err := a.Get(ctx, "/api/v1/event", &res); err != nil {
+	if err := a.getCached(ctx, EventsPath, &res); err != nil {
 		return nil, err
 	}
 	return res.Events, nil
@@ -17,8 +17,9 @@ type groupResp struct {
 
 // Groups returns a list of groups for the acccount
 func (a *API) Groups(ctx context.Context) (Groups, error) {
+	ctx = withOperation(ctx, "Groups")
 	var res groupResp
-	if err := a.Get(ctx, "/api/v1/group", &res); err != nil {
+	if err := a.getCached(ctx, GroupsPath, &res); err != nil {
 		return nil, err
 	}
 	return res.Groups, nil
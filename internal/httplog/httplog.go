@@ -0,0 +1,68 @@
+// Package httplog logs HTTP requests to the MemberClicks APIs with secrets and
+// personal data redacted.
+package httplog
+
+import (
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Log logs a request and its response. The method, path, status and duration are
+// logged at info level, or at warn level with the error if the request failed. The headers and
+// bodies are only logged at debug level, with the Fields, Headers and the extra
+// fields redacted.
+func Log(ctx context.Context, logger *slog.Logger, req *http.Request, resp *http.Response, respBody []byte, err error, d time.Duration, extra ...string) {
+	if logger == nil {
+		return
+	}
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Duration("duration", d),
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	}
+	if err != nil {
+		attrs = append(attrs, errorAttr(err))
+	}
+	if logger.Enabled(ctx, slog.LevelDebug) {
+		attrs = append(attrs, slog.Any("request_header", RedactHeader(req.Header)))
+		if req.GetBody != nil {
+			if body, err := req.GetBody(); err == nil {
+				b, _ := ioutil.ReadAll(body)
+				body.Close()
+				attrs = append(attrs, slog.String("request_body", RedactBody(b, req.Header.Get("Content-Type"), extra...)))
+			}
+		}
+		if resp != nil {
+			attrs = append(attrs,
+				slog.Any("response_header", RedactHeader(resp.Header)),
+				slog.String("response_body", RedactBody(respBody, resp.Header.Get("Content-Type"), extra...)),
+			)
+		}
+	}
+	logger.LogAttrs(ctx, level, "memberclicks request", attrs...)
+}
+
+// errorAttr returns the attribute of err. Errors which are slog.LogValuers, like API
+// errors, are logged by their value, because their message may be the raw response body.
+func errorAttr(err error) slog.Attr {
+	var v slog.LogValuer
+	if errors.As(err, &v) {
+		return slog.Any("error", v)
+	}
+	return slog.String("error", err.Error())
+}
@@ -0,0 +1,63 @@
+package httplog
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestLog(t *testing.T) {
+	ctx := context.Background()
+	req, _ := http.NewRequest("POST", "https://demo.memberclicks.net/oauth/v1/token", strings.NewReader("grant_type=password&username=jdoe&password=secret"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("id", "secret")
+	resp := &http.Response{StatusCode: 200, Header: http.Header{"Content-Type": {"application/json"}}}
+	body := []byte(`{"access_token":"abc","userId":1}`)
+
+	var buf bytes.Buffer
+	Log(ctx, slog.New(slog.NewTextHandler(&buf, nil)), req, resp, body, nil, time.Second)
+	assert.Contains(t, buf.String(), "level=INFO")
+	assert.Contains(t, buf.String(), "method=POST path=/oauth/v1/token duration=1s status=200")
+	assert.NotContains(t, buf.String(), "body")
+
+	buf.Reset()
+	Log(ctx, slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), req, resp, body, nil, time.Second)
+	assert.Contains(t, buf.String(), `password=REDACTED`)
+	assert.Contains(t, buf.String(), `\"access_token\":\"REDACTED\"`)
+	assert.Contains(t, buf.String(), `"Authorization":["REDACTED"]`)
+	assert.NotContains(t, buf.String(), "secret")
+	assert.NotContains(t, buf.String(), "abc")
+
+	buf.Reset()
+	Log(ctx, slog.New(slog.NewTextHandler(&buf, nil)), req, nil, nil, errors.New("connection refused"), time.Second)
+	assert.Contains(t, buf.String(), `level=WARN`)
+	assert.Contains(t, buf.String(), `error="connection refused"`)
+
+	buf.Reset()
+	Log(ctx, slog.New(slog.NewTextHandler(&buf, nil)), req, resp, body, bodyError(body), time.Second)
+	assert.Contains(t, buf.String(), `error.status=500`)
+	assert.NotContains(t, buf.String(), "abc")
+
+	assert.NotPanics(t, func() {
+		Log(ctx, nil, req, resp, body, nil, time.Second)
+	})
+}
+
+// bodyError is an error whose message is a response body, like an API error without a
+// parsed error message
+type bodyError []byte
+
+func (e bodyError) Error() string {
+	return string(e)
+}
+
+func (e bodyError) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("status", 500))
+}
@@ -0,0 +1,131 @@
+package httplog
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the redacted values
+const Redacted = "REDACTED"
+
+var (
+	// Fields are always redacted from bodies
+	Fields = []string{"password", "client_secret", "apiKey", "access_token", "refresh_token", "token"}
+
+	// Headers are always redacted
+	Headers = []string{"Authorization", "Cookie", "Set-Cookie"}
+)
+
+// RedactHeader returns a copy of h with the values of sensitive headers redacted
+func RedactHeader(h http.Header) http.Header {
+	c := h.Clone()
+	for _, k := range Headers {
+		if _, ok := c[k]; ok {
+			c[k] = []string{Redacted}
+		}
+	}
+	return c
+}
+
+// RedactBody returns the body with the values of Fields and the extra fields redacted.
+// JSON, form encoded and XML bodies are supported, other bodies are returned as is.
+func RedactBody(body []byte, contentType string, extra ...string) string {
+	if len(body) == 0 {
+		return ""
+	}
+	fields := map[string]bool{}
+	for _, f := range append(Fields, extra...) {
+		fields[strings.ToLower(f)] = true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasSuffix(mediaType, "json"):
+		return jsonBody(body, fields)
+	case mediaType == "application/x-www-form-urlencoded":
+		return formBody(body, fields)
+	case strings.HasSuffix(mediaType, "xml"):
+		return xmlBody(body, fields)
+	}
+	return string(body)
+}
+
+func jsonBody(body []byte, fields map[string]bool) string {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return string(body)
+	}
+	b, err := json.Marshal(jsonValue(v, fields))
+	if err != nil {
+		return string(body)
+	}
+	return string(b)
+}
+
+func jsonValue(v interface{}, fields map[string]bool) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		// MemberClicks classic attributes are name/data pairs
+		if name, ok := val["attName"].(string); ok && fields[strings.ToLower(name)] {
+			if _, ok := val["attData"]; ok {
+				val["attData"] = Redacted
+			}
+		}
+		for k := range val {
+			if fields[strings.ToLower(k)] {
+				val[k] = Redacted
+			} else {
+				val[k] = jsonValue(val[k], fields)
+			}
+		}
+	case []interface{}:
+		for i := range val {
+			val[i] = jsonValue(val[i], fields)
+		}
+	}
+	return v
+}
+
+func formBody(body []byte, fields map[string]bool) string {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return string(body)
+	}
+	for k := range form {
+		if fields[strings.ToLower(k)] {
+			form[k] = []string{Redacted}
+		}
+	}
+	return form.Encode()
+}
+
+var (
+	xmlElement   = regexp.MustCompile(`(?s)<([\w:.-]+)(\s[^>]*)?>([^<]*)</([\w:.-]+)>`)
+	xmlAttribute = regexp.MustCompile(`(?s)<attribute>.*?</attribute>`)
+	xmlAttName   = regexp.MustCompile(`<attName>([^<]*)</attName>`)
+	xmlAttData   = regexp.MustCompile(`<attData>[^<]*</attData>`)
+)
+
+func xmlBody(body []byte, fields map[string]bool) string {
+	// MemberClicks classic attributes are name/data pairs
+	redacted := xmlAttribute.ReplaceAllStringFunc(string(body), func(el string) string {
+		m := xmlAttName.FindStringSubmatch(el)
+		if m == nil || !fields[strings.ToLower(strings.TrimSpace(m[1]))] {
+			return el
+		}
+		return xmlAttData.ReplaceAllString(el, "<attData>"+Redacted+"</attData>")
+	})
+	return xmlElement.ReplaceAllStringFunc(redacted, func(el string) string {
+		m := xmlElement.FindStringSubmatch(el)
+		if m[1] != m[4] || !fields[strings.ToLower(m[1])] {
+			return el
+		}
+		return "<" + m[1] + m[2] + ">" + Redacted + "</" + m[4] + ">"
+	})
+}
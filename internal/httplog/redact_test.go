@@ -0,0 +1,53 @@
+package httplog
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactHeader(t *testing.T) {
+	h := http.Header{"Authorization": {"Bearer abc"}, "Accept": {"application/json"}}
+	r := RedactHeader(h)
+	assert.Equal(t, Redacted, r.Get("Authorization"))
+	assert.Equal(t, "application/json", r.Get("Accept"))
+	assert.Equal(t, "Bearer abc", h.Get("Authorization"))
+}
+
+func TestRedactBodyJSON(t *testing.T) {
+	body := []byte(`{"access_token":"abc","expires_in":3600,"profiles":[{"[Profile ID]":1002625212,"[Email | Primary]":"a@example.com","Password":"x"}]}`)
+	assert.JSONEq(t,
+		`{"access_token":"REDACTED","expires_in":3600,"profiles":[{"[Profile ID]":1002625212,"[Email | Primary]":"REDACTED","Password":"REDACTED"}]}`,
+		RedactBody(body, "application/json;charset=UTF-8", "[Email | Primary]"),
+	)
+	assert.Equal(t, "not json", RedactBody([]byte("not json"), "application/json"))
+}
+
+func TestRedactBodyForm(t *testing.T) {
+	body := []byte("grant_type=password&username=jdoe&password=secret")
+	assert.Equal(t, "grant_type=password&password=REDACTED&username=jdoe", RedactBody(body, "application/x-www-form-urlencoded"))
+}
+
+func TestRedactBodyXML(t *testing.T) {
+	body := []byte(`<auth><userName>jdoe</userName><token>abc</token><Password>x</Password></auth>`)
+	assert.Equal(t, `<auth><userName>jdoe</userName><token>REDACTED</token><Password>REDACTED</Password></auth>`, RedactBody(body, "application/xml"))
+	assert.Equal(t, `<auth><userName>REDACTED</userName><token>REDACTED</token><Password>REDACTED</Password></auth>`, RedactBody(body, "text/xml", "username"))
+}
+
+func TestRedactBodyAttributes(t *testing.T) {
+	body := []byte(`<user><attribute><attName>Email</attName><attData>a@example.com</attData></attribute><attribute><attName>City</attName><attData>Atlanta</attData></attribute></user>`)
+	assert.Equal(t,
+		`<user><attribute><attName>Email</attName><attData>REDACTED</attData></attribute><attribute><attName>City</attName><attData>Atlanta</attData></attribute></user>`,
+		RedactBody(body, "application/xml", "email"),
+	)
+	assert.JSONEq(t,
+		`{"attribute":[{"attName":"Email","attData":"REDACTED"},{"attName":"City","attData":"Atlanta"}]}`,
+		RedactBody([]byte(`{"attribute":[{"attName":"Email","attData":"a@example.com"},{"attName":"City","attData":"Atlanta"}]}`), "application/json", "Email"),
+	)
+}
+
+func TestRedactBodyOther(t *testing.T) {
+	assert.Equal(t, "", RedactBody(nil, "application/json"))
+	assert.Equal(t, "password=x", RedactBody([]byte("password=x"), "text/plain"))
+}
@@ -0,0 +1,116 @@
+// Package tracing creates OpenTelemetry spans for the calls to the MemberClicks APIs.
+package tracing
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+const instrumentationName = "github.com/bradberger/go-memberclicks"
+
+// Span attribute keys
+const (
+	OrgID      = attribute.Key("memberclicks.org_id")
+	PageNumber = attribute.Key("memberclicks.page_number")
+	PageSize   = attribute.Key("memberclicks.page_size")
+	RetryCount = attribute.Key("memberclicks.retry_count")
+	Method     = attribute.Key("http.request.method")
+	Path       = attribute.Key("url.path")
+	StatusCode = attribute.Key("http.response.status_code")
+)
+
+type operationKey struct{}
+
+// WithOperation returns a context which names the spans of requests made with it after operation
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// Operation returns the operation name of ctx, or fallback if it has none
+func Operation(ctx context.Context, fallback string) string {
+	if op, ok := ctx.Value(operationKey{}).(string); ok && op != "" {
+		return op
+	}
+	return fallback
+}
+
+// Start starts a client span with the tracer provider, or the global one if tp is nil
+func Start(ctx context.Context, tp trace.TracerProvider, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// StartRequest starts a span for req and propagates its trace context in the request headers
+func StartRequest(ctx context.Context, tp trace.TracerProvider, name string, req *http.Request, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, Method.String(req.Method), Path.String(req.URL.Path))
+	q := req.URL.Query()
+	for _, p := range []struct {
+		key   attribute.Key
+		param []string
+	}{
+		{PageNumber, []string{"pageNumber", "pageNum"}},
+		{PageSize, []string{"pageSize"}},
+	} {
+		for _, param := range p.param {
+			if i, err := strconv.Atoi(q.Get(param)); err == nil {
+				attrs = append(attrs, p.key.Int(i))
+			}
+		}
+	}
+	ctx, span := Start(ctx, tp, name, attrs...)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return ctx, span
+}
+
+// SetStatus sets the HTTP status code of the response on the span of ctx
+func SetStatus(ctx context.Context, statusCode int) {
+	trace.SpanFromContext(ctx).SetAttributes(StatusCode.Int(statusCode))
+}
+
+// End records the number of retries and the error, if any, and ends the span
+func End(span trace.Span, retries int, err error) {
+	if retries < 0 {
+		retries = 0
+	}
+	span.SetAttributes(RetryCount.Int(retries))
+	if err != nil {
+		msg := errorMessage(err)
+		span.AddEvent("exception", trace.WithAttributes(
+			attribute.String("exception.type", fmt.Sprintf("%T", err)),
+			attribute.String("exception.message", msg),
+		))
+		span.SetStatus(codes.Error, msg)
+	}
+	span.End()
+}
+
+// errorMessage returns the message of err to export. Errors which are slog.LogValuers, like
+// API errors, are exported by their value, because their message may be the raw response body.
+func errorMessage(err error) string {
+	var v slog.LogValuer
+	if !errors.As(err, &v) {
+		return err.Error()
+	}
+	value := v.LogValue().Resolve()
+	if value.Kind() != slog.KindGroup {
+		return value.String()
+	}
+	parts := make([]string, 0, len(value.Group()))
+	for _, a := range value.Group() {
+		parts = append(parts, a.String())
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/net/context"
+)
+
+func TestOperation(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "Do", Operation(ctx, "Do"))
+	assert.Equal(t, "Users", Operation(WithOperation(ctx, "Users"), "Do"))
+}
+
+func TestStartRequest(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	req, _ := http.NewRequest("GET", "https://demo.memberclicks.net/services/user?pageNum=2&pageSize=10", nil)
+
+	ctx, span := StartRequest(context.Background(), tp, "memberclicks.classic.Users", req, OrgID.String("demo"))
+	SetStatus(ctx, 500)
+	End(span, 2, errors.New("server error"))
+
+	assert.NotEmpty(t, req.Header.Get("Traceparent"))
+	if !assert.Len(t, rec.Ended(), 1) {
+		return
+	}
+	s := rec.Ended()[0]
+	assert.Equal(t, "memberclicks.classic.Users", s.Name())
+	assert.Equal(t, codes.Error, s.Status().Code)
+	assert.Contains(t, s.Attributes(), PageNumber.Int(2))
+	assert.Contains(t, s.Attributes(), PageSize.Int(10))
+	assert.Contains(t, s.Attributes(), RetryCount.Int(2))
+	assert.Contains(t, s.Attributes(), StatusCode.Int(500))
+	assert.Contains(t, s.Attributes(), Path.String("/services/user"))
+}
+
+// redactedError is an error like the API errors, which has a raw response body as its message
+type redactedError struct{}
+
+func (redactedError) Error() string { return `{"access_token":"leaked"}` }
+func (redactedError) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("status", 400), slog.String("message", "Invalid grant"))
+}
+
+func TestEndError(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+
+	_, span := Start(context.Background(), tp, "memberclicks.Auth")
+	End(span, 0, fmt.Errorf("memberclicks: %w", redactedError{}))
+	_, span = Start(context.Background(), tp, "memberclicks.Groups")
+	End(span, 0, errors.New("connection refused"))
+
+	if !assert.Len(t, rec.Ended(), 2) {
+		return
+	}
+	s := rec.Ended()[0]
+	assert.Equal(t, "status=400 message=Invalid grant", s.Status().Description)
+	if assert.Len(t, s.Events(), 1) {
+		assert.Contains(t, s.Events()[0].Attributes, attribute.String("exception.message", "status=400 message=Invalid grant"))
+	}
+	assert.NotContains(t, fmt.Sprint(s.Events(), s.Status()), "leaked")
+	assert.Equal(t, "connection refused", rec.Ended()[1].Status().Description)
+}
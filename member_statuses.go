@@ -17,8 +17,9 @@ type memberStatusResp struct {
 
 // MemberStatuses returns the complete list of member statuses.
 func (a *API) MemberStatuses(ctx context.Context) (MemberStatuses, error) {
+	ctx = withOperation(ctx, "MemberStatuses")
 	var res memberStatusResp
-	if err := a.Get(ctx, "/api/v1/member-status", &res); err != nil {
+	if err := a.getCached(ctx, MemberStatusesPath, &res); err != nil {
 		return nil, err
 	}
 	return res.MemberStatuses, nil
@@ -18,8 +18,9 @@ type memberTypeResp struct {
 
 // MemberTypes returns a slice of member types for the account
 func (a *API) MemberTypes(ctx context.Context) (MemberTypes, error) {
+	ctx = withOperation(ctx, "MemberTypes")
 	var res memberTypeResp
-	if err := a.Get(ctx, "/api/v1/member-type", &res); err != nil {
+	if err := a.getCached(ctx, MemberTypesPath, &res); err != nil {
 		return nil, err
 	}
 	return res.MemberTypes, nil
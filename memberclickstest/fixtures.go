@@ -0,0 +1,107 @@
+package memberclickstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Default credentials of the fixtures returned by DefaultFixtures
+const (
+	DefaultClientID     = "test-client"
+	DefaultClientSecret = "test-secret"
+	DefaultUsername     = "jdoe"
+	DefaultPassword     = "password"
+	DefaultProfileID    = 1002583186
+)
+
+// Fixtures is the data the fake server is seeded with. The reference data can be
+// any values which encode to the JSON the API returns, like the memberclicks.Country
+// structs or maps.
+type Fixtures struct {
+	// Clients maps OAuth client IDs to their secrets
+	Clients map[string]string `json:"clients"`
+	Users   []User            `json:"users"`
+
+	// Profiles are the profile attributes, each needs a "[Profile ID]"
+	Profiles       []map[string]interface{} `json:"profiles"`
+	Countries      []interface{}            `json:"countries"`
+	Events         []interface{}            `json:"events"`
+	Groups         []interface{}            `json:"groups"`
+	MemberStatuses []interface{}            `json:"memberStatuses"`
+	MemberTypes    []interface{}            `json:"memberTypes"`
+}
+
+// User is a member who can sign in with the password and authorization code grants
+type User struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	ProfileID int64  `json:"profileId"`
+}
+
+// ReadFixtures decodes JSON encoded fixtures from r
+func ReadFixtures(r io.Reader) (*Fixtures, error) {
+	var f Fixtures
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// LoadFixtures reads JSON encoded fixtures from the file at path
+func LoadFixtures(path string) (*Fixtures, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadFixtures(f)
+}
+
+// DefaultFixtures returns one client with DefaultClientID and DefaultClientSecret,
+// one user with DefaultUsername and DefaultPassword, 25 profiles starting with
+// DefaultProfileID, and a few of each kind of reference data.
+func DefaultFixtures() *Fixtures {
+	f := &Fixtures{
+		Clients: map[string]string{DefaultClientID: DefaultClientSecret},
+		Users:   []User{{Username: DefaultUsername, Password: DefaultPassword, ProfileID: DefaultProfileID}},
+		Countries: []interface{}{
+			map[string]interface{}{"name": "Canada"},
+			map[string]interface{}{"name": "United States"},
+		},
+		Events: []interface{}{
+			map[string]interface{}{"name": "Annual Meeting", "date": "10/14/2017"},
+		},
+		Groups: []interface{}{
+			map[string]interface{}{"name": "Board"},
+			map[string]interface{}{"name": "Members"},
+		},
+		MemberStatuses: []interface{}{
+			map[string]interface{}{"name": "Active"},
+			map[string]interface{}{"name": "Lapsed"},
+		},
+		MemberTypes: []interface{}{
+			map[string]interface{}{"name": "Individual", "type": "Individual"},
+			map[string]interface{}{"name": "Organization", "type": "Organization"},
+		},
+	}
+	today := time.Now().Format("01/02/2006")
+	for i := 0; i < 25; i++ {
+		group := "Members"
+		if i == 0 {
+			group = "Board"
+		}
+		f.Profiles = append(f.Profiles, map[string]interface{}{
+			"[Profile ID]":         DefaultProfileID + i,
+			"[Contact Name]":       fmt.Sprintf("Member %d", i+1),
+			"[Email | Primary]":    fmt.Sprintf("member%d@example.com", i+1),
+			"[Member Type]":        "Individual",
+			"[Member Status]":      "Active",
+			"[Group]":              []interface{}{group},
+			"[Last Modified Date]": today,
+		})
+	}
+	return f
+}
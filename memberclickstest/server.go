@@ -0,0 +1,578 @@
+// Package memberclickstest provides an in-process fake of the MemberClicks API for
+// testing clients without credentials or network access.
+package memberclickstest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// TokenTTL is how long access tokens issued by new servers are valid
+	TokenTTL = time.Hour
+
+	// SearchTTL is how long profile searches created on new servers are valid
+	SearchTTL = 30 * time.Minute
+)
+
+// Server is a fake MemberClicks API server. Point a client at it with its URL as the
+// client's BaseURL.
+type Server struct {
+	*httptest.Server
+
+	// TokenTTL and SearchTTL are initialized from the package defaults
+	TokenTTL, SearchTTL time.Duration
+
+	mu       sync.Mutex
+	fixtures *Fixtures
+	tokens   map[string]*token
+	refresh  map[string]*token
+	codes    map[string]*code
+	searches map[string]*search
+	faults   []*Fault
+	requests map[string]int
+}
+
+type token struct {
+	Scope   string
+	User    *User
+	Expires time.Time
+}
+
+// code is an authorization code, with the PKCE code challenge it was requested with if any
+type code struct {
+	User      *User
+	Challenge string
+}
+
+type search struct {
+	Params  map[string]interface{}
+	Expires time.Time
+}
+
+// Fault makes the server misbehave for matching requests
+type Fault struct {
+	// Path is the path prefix of requests the fault applies to. Empty matches all requests.
+	Path string
+	// Latency delays the response
+	Latency time.Duration
+	// StatusCode, if set, is returned instead of handling the request
+	StatusCode int
+	// Header is added to the response, like Retry-After for 429s
+	Header http.Header
+	// Times is how many requests the fault applies to, 0 means all of them
+	Times int
+}
+
+// NewServer starts a fake server seeded with the fixtures. If f is nil, DefaultFixtures are used.
+func NewServer(f *Fixtures) *Server {
+	s := newServer(f)
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSServer starts a fake HTTPS server like NewServer. Clients have to trust its
+// certificate, like the Client method of the server does.
+func NewTLSServer(f *Fixtures) *Server {
+	s := newServer(f)
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+func newServer(f *Fixtures) *Server {
+	if f == nil {
+		f = DefaultFixtures()
+	}
+	s := &Server{
+		TokenTTL:  TokenTTL,
+		SearchTTL: SearchTTL,
+		fixtures:  f,
+		tokens:    map[string]*token{},
+		refresh:   map[string]*token{},
+		codes:     map[string]*code{},
+		searches:  map[string]*search{},
+		requests:  map[string]int{},
+	}
+	return s
+}
+
+// AddProfile adds a profile with the given attributes
+func (s *Server) AddProfile(attrs map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixtures.Profiles = append(s.fixtures.Profiles, attrs)
+}
+
+// Inject adds a fault to the server
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes all faults
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// ExpireTokens expires all access tokens issued so far. Refresh tokens keep working.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tokens {
+		t.Expires = time.Now()
+	}
+}
+
+// ExpireSearches expires all profile searches created so far
+func (s *Server) ExpireSearches() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, srch := range s.searches {
+		srch.Expires = time.Now()
+	}
+}
+
+// AuthCode issues an authorization code for the user, as if they signed in on the
+// authorize page. It returns an empty string if there is no such user.
+func (s *Server) AuthCode(username string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.user(username)
+	if u == nil {
+		return ""
+	}
+	return s.issueCode(u, "")
+}
+
+// issueCode creates an authorization code. The caller must hold the lock.
+func (s *Server) issueCode(u *User, challenge string) string {
+	c := randomID()
+	s.codes[c] = &code{User: u, Challenge: challenge}
+	return c
+}
+
+// Requests returns how many requests were received for the method and path, for example "GET /api/v1/group"
+func (s *Server) Requests(methodPath string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[methodPath]
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.Method+" "+r.URL.Path]++
+	f := s.fault(r.URL.Path)
+	s.mu.Unlock()
+
+	if f != nil {
+		time.Sleep(f.Latency)
+		for k, v := range f.Header {
+			w.Header()[k] = v
+		}
+		if f.StatusCode > 0 {
+			s.error(w, r, f.StatusCode, "injected fault")
+			return
+		}
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case path == "/oauth/v1/authorize" && r.Method == "GET":
+		s.authorize(w, r)
+	case path == "/oauth/v1/token" && r.Method == "POST":
+		s.token(w, r)
+	case !strings.HasPrefix(path, "/api/v1/"):
+		s.error(w, r, http.StatusNotFound, "Not Found")
+	default:
+		tok := s.authenticate(r)
+		if tok == nil {
+			s.error(w, r, http.StatusUnauthorized, "Full authentication is required to access this resource")
+			return
+		}
+		s.api(w, r, path, tok)
+	}
+}
+
+// fault returns the first fault matching path, counting it as applied
+func (s *Server) fault(path string) *Fault {
+	for i, f := range s.faults {
+		if !strings.HasPrefix(path, f.Path) {
+			continue
+		}
+		if f.Times > 0 {
+			if f.Times--; f.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func (s *Server) api(w http.ResponseWriter, r *http.Request, path string, tok *token) {
+	reference := map[string]struct {
+		key  string
+		list func() []interface{}
+	}{
+		"/api/v1/country":       {"countries", func() []interface{} { return s.fixtures.Countries }},
+		"/api/v1/event":         {"events", func() []interface{} { return s.fixtures.Events }},
+		"/api/v1/group":         {"groups", func() []interface{} { return s.fixtures.Groups }},
+		"/api/v1/member-status": {"memberStatuses", func() []interface{} { return s.fixtures.MemberStatuses }},
+		"/api/v1/member-type":   {"memberTypes", func() []interface{} { return s.fixtures.MemberTypes }},
+	}
+	if ref, ok := reference[path]; ok && r.Method == "GET" {
+		s.mu.Lock()
+		list := ref.list()
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"totalCount": len(list), ref.key: list})
+		return
+	}
+
+	switch {
+	case path == "/api/v1/profile" && r.Method == "GET":
+		s.profiles(w, r)
+	case path == "/api/v1/profile/search" && r.Method == "POST":
+		s.createSearch(w, r)
+	case path == "/api/v1/profile/me" && r.Method == "GET":
+		if tok.User == nil {
+			s.error(w, r, http.StatusNotFound, "Token is not associated with a profile")
+			return
+		}
+		s.profile(w, r, strconv.FormatInt(tok.User.ProfileID, 10))
+	case strings.HasPrefix(path, "/api/v1/profile/") && r.Method == "GET":
+		s.profile(w, r, strings.TrimPrefix(path, "/api/v1/profile/"))
+	default:
+		s.error(w, r, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	_, ok := s.fixtures.Clients[q.Get("client_id")]
+	var u *User
+	if len(s.fixtures.Users) > 0 {
+		u = &s.fixtures.Users[0]
+	}
+	s.mu.Unlock()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if !ok || u == nil || err != nil || q.Get("redirect_uri") == "" {
+		s.error(w, r, http.StatusBadRequest, "Invalid authorization request")
+		return
+	}
+
+	// Signs in as the first user, without a sign in page.
+	v := redirect.Query()
+	switch q.Get("response_type") {
+	case "code":
+		challenge := q.Get("code_challenge")
+		if challenge != "" && q.Get("code_challenge_method") != "S256" {
+			s.error(w, r, http.StatusBadRequest, "Unsupported code challenge method")
+			return
+		}
+		s.mu.Lock()
+		v.Set("code", s.issueCode(u, challenge))
+		s.mu.Unlock()
+		v.Set("state", q.Get("state"))
+		redirect.RawQuery = v.Encode()
+	case "token":
+		s.mu.Lock()
+		access, _ := s.issue(q.Get("scope"), u)
+		s.mu.Unlock()
+		redirect.Fragment = url.Values{"access_token": {access}, "token_type": {"bearer"}, "state": {q.Get("state")}}.Encode()
+	default:
+		s.error(w, r, http.StatusBadRequest, "Unsupported response type")
+		return
+	}
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Public clients authenticate with the PKCE code verifier instead of a secret, and
+	// renew their tokens with the refresh token only
+	grant := r.PostFormValue("grant_type")
+	public := !ok && (grant == "authorization_code" && r.PostFormValue("code_verifier") != "" || grant == "refresh_token")
+	if public {
+		clientID = r.PostFormValue("client_id")
+	}
+	if want, exists := s.fixtures.Clients[clientID]; !exists || (!public && (!ok || want != secret)) {
+		s.error(w, r, http.StatusUnauthorized, "Bad client credentials")
+		return
+	}
+
+	var u *User
+	scope := r.PostFormValue("scope")
+	switch r.PostFormValue("grant_type") {
+	case "client_credentials":
+	case "password":
+		u = s.user(r.PostFormValue("username"))
+		if u == nil || u.Password != r.PostFormValue("password") {
+			s.error(w, r, http.StatusBadRequest, "Bad credentials")
+			return
+		}
+	case "authorization_code":
+		c := s.codes[r.PostFormValue("code")]
+		if c == nil {
+			s.error(w, r, http.StatusBadRequest, "Invalid authorization code")
+			return
+		}
+		delete(s.codes, r.PostFormValue("code"))
+		if (c.Challenge != "" || public) && c.Challenge != challengeS256(r.PostFormValue("code_verifier")) {
+			s.error(w, r, http.StatusBadRequest, "Invalid code verifier")
+			return
+		}
+		u = c.User
+	case "refresh_token":
+		t := s.refresh[r.PostFormValue("refresh_token")]
+		if t == nil {
+			s.error(w, r, http.StatusBadRequest, "Invalid refresh token")
+			return
+		}
+		u, scope = t.User, t.Scope
+	default:
+		s.error(w, r, http.StatusBadRequest, "Unsupported grant type")
+		return
+	}
+	if scope == "" {
+		scope = "read"
+	}
+
+	access, t := s.issue(scope, u)
+	resp := map[string]interface{}{
+		"access_token": access,
+		"token_type":   "bearer",
+		"expires_in":   int64(s.TokenTTL / time.Second),
+		"scope":        scope,
+		"serviceId":    1,
+		"jti":          randomID(),
+	}
+	if u != nil {
+		refresh := randomID()
+		s.refresh[refresh] = t
+		resp["refresh_token"] = refresh
+		resp["userId"] = u.ProfileID
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// issue creates an access token. The caller must hold the lock.
+func (s *Server) issue(scope string, u *User) (string, *token) {
+	access := randomID()
+	t := &token{Scope: scope, User: u, Expires: time.Now().Add(s.TokenTTL)}
+	s.tokens[access] = t
+	return access, t
+}
+
+// user returns the user with the given username. The caller must hold the lock.
+func (s *Server) user(username string) *User {
+	for i := range s.fixtures.Users {
+		if s.fixtures.Users[i].Username == username {
+			return &s.fixtures.Users[i]
+		}
+	}
+	return nil
+}
+
+// authenticate returns the valid access token of the request, or nil
+func (s *Server) authenticate(r *http.Request) *token {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.tokens[auth[7:]]
+	if t == nil || !time.Now().Before(t.Expires) {
+		return nil
+	}
+	return t
+}
+
+func (s *Server) profile(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.fixtures.Profiles {
+		if idString(p["[Profile ID]"]) == id {
+			writeJSON(w, http.StatusOK, p)
+			return
+		}
+	}
+	s.error(w, r, http.StatusNotFound, "Profile not found")
+}
+
+func (s *Server) profiles(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	pageNumber, pageSize := queryInt(q, "pageNumber", 1), queryInt(q, "pageSize", 10)
+	if pageNumber < 1 || pageSize < 1 || pageSize > 100 {
+		s.error(w, r, http.StatusBadRequest, "Invalid page number or size")
+		return
+	}
+
+	s.mu.Lock()
+	profiles := s.fixtures.Profiles
+	if id := q.Get("searchId"); id != "" {
+		srch := s.searches[id]
+		if srch == nil || !time.Now().Before(srch.Expires) {
+			s.mu.Unlock()
+			s.error(w, r, http.StatusNotFound, "Search not found or expired")
+			return
+		}
+		profiles = filter(profiles, srch.Params)
+	}
+	s.mu.Unlock()
+
+	pageCount := (len(profiles) + pageSize - 1) / pageSize
+	if pageCount == 0 {
+		pageCount = 1
+	}
+	if pageNumber > pageCount {
+		s.error(w, r, http.StatusBadRequest, "Page number out of range")
+		return
+	}
+	start := (pageNumber - 1) * pageSize
+	end := start + pageSize
+	if end > len(profiles) {
+		end = len(profiles)
+	}
+	page := profiles[start:end]
+
+	pageURL := func(n int) string {
+		v := url.Values{}
+		for k := range q {
+			v.Set(k, q.Get(k))
+		}
+		v.Set("pageNumber", strconv.Itoa(n))
+		v.Set("pageSize", strconv.Itoa(pageSize))
+		return s.URL + "/api/v1/profile?" + v.Encode()
+	}
+	resp := map[string]interface{}{
+		"totalCount":     len(profiles),
+		"totalPageCount": pageCount,
+		"pageNumber":     pageNumber,
+		"pageSize":       pageSize,
+		"count":          len(page),
+		"firstPageUrl":   pageURL(1),
+		"lastPageUrl":    pageURL(pageCount),
+		"profiles":       page,
+	}
+	if pageNumber < pageCount {
+		resp["nextPageUrl"] = pageURL(pageNumber + 1)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) createSearch(w http.ResponseWriter, r *http.Request) {
+	var params map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		s.error(w, r, http.StatusBadRequest, "Invalid search: "+err.Error())
+		return
+	}
+	id := randomID()
+	now := time.Now()
+	s.mu.Lock()
+	srch := &search{Params: params, Expires: now.Add(s.SearchTTL)}
+	s.searches[id] = srch
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":          id,
+		"expireDate":  srch.Expires.Format(time.RFC3339),
+		"status":      http.StatusOK,
+		"timestamp":   now.Unix(),
+		"url":         s.URL + "/api/v1/profile/search/" + id,
+		"item":        params,
+		"profilesUrl": s.URL + "/api/v1/profile?searchId=" + id,
+	})
+}
+
+// filter returns the profiles where every search param equals the attribute of the same name.
+// For list attributes like [Group], one of the values has to match.
+func filter(profiles []map[string]interface{}, params map[string]interface{}) []map[string]interface{} {
+	var res []map[string]interface{}
+	for _, p := range profiles {
+		match := true
+		for k, want := range params {
+			if !matches(p[k], fmt.Sprint(want)) {
+				match = false
+				break
+			}
+		}
+		if match {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func matches(val interface{}, want string) bool {
+	if list, ok := val.([]interface{}); ok {
+		for i := range list {
+			if matches(list[i], want) {
+				return true
+			}
+		}
+		return false
+	}
+	return val != nil && strings.EqualFold(idString(val), want)
+}
+
+// error writes a MemberClicks error response
+func (s *Server) error(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"timestamp": time.Now().Unix(),
+		"status":    status,
+		"error":     http.StatusText(status),
+		"message":   message,
+		"path":      r.URL.Path,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func queryInt(q url.Values, key string, def int) int {
+	if i, err := strconv.Atoi(q.Get(key)); err == nil {
+		return i
+	}
+	return def
+}
+
+// idString formats attribute values, without exponents for IDs decoded from JSON fixtures
+func idString(val interface{}) string {
+	if f, ok := val.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(val)
+}
+
+// challengeS256 returns the S256 PKCE code challenge of the code verifier
+func challengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,168 @@
+package memberclickstest_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks"
+	"github.com/bradberger/go-memberclicks/memberclickstest"
+)
+
+var ctx = context.Background()
+
+func newAPI(srv *memberclickstest.Server) *memberclicks.API {
+	a, _ := memberclicks.New("test", memberclickstest.DefaultClientID, memberclickstest.DefaultClientSecret, memberclicks.WithBaseURL(srv.URL))
+	return a
+}
+
+func TestServerAuth(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	a := newAPI(srv)
+	_, err := a.Groups(ctx)
+	assert.True(t, errors.Is(err, memberclicks.ErrUnauthorized))
+
+	assert.NoError(t, a.Auth(ctx))
+	g, err := a.Groups(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, g, 2)
+
+	bad, _ := memberclicks.New("test", memberclickstest.DefaultClientID, "wrong", memberclicks.WithBaseURL(srv.URL))
+	assert.True(t, errors.Is(bad.Auth(ctx), memberclicks.ErrUnauthorized))
+}
+
+func TestServerPassword(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	a := newAPI(srv)
+	assert.Error(t, a.CheckPassword(ctx, memberclickstest.DefaultUsername, "wrong"))
+	tok, err := a.OwnerPassword(ctx, memberclickstest.DefaultUsername, memberclickstest.DefaultPassword)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.EqualValues(t, memberclickstest.DefaultProfileID, tok.UserID)
+	assert.NotEmpty(t, tok.RefreshToken)
+
+	p, err := a.Me(ctx, tok.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "1002583186", p.GetID())
+
+	refreshed, err := a.RefreshToken(ctx, "read", tok.RefreshToken)
+	assert.NoError(t, err)
+	assert.NotEqual(t, tok.AccessToken, refreshed.AccessToken)
+}
+
+func TestServerAuthCode(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	a := newAPI(srv)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(a.GetAuthCodeURL("read", "xyz", "http://localhost/callback"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	resp.Body.Close()
+	loc, err := resp.Location()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "xyz", loc.Query().Get("state"))
+
+	tok, err := a.GetToken(ctx, loc.Query().Get("code"), "read", "xyz", "http://localhost/callback")
+	assert.NoError(t, err)
+	assert.EqualValues(t, memberclickstest.DefaultProfileID, tok.UserID)
+
+	_, err = a.GetToken(ctx, loc.Query().Get("code"), "read", "xyz", "http://localhost/callback")
+	assert.Error(t, err, "codes can only be used once")
+}
+
+func TestServerProfiles(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	a := newAPI(srv)
+	assert.NoError(t, a.Auth(ctx))
+
+	resp, err := a.Profiles(ctx, 3, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 25, resp.TotalCount)
+	assert.Equal(t, 3, resp.TotalPageCount)
+	assert.Len(t, resp.Profiles, 5)
+	assert.Empty(t, resp.NextPageURL)
+
+	all, err := a.Profiles(ctx, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, all.Profiles, 25)
+
+	_, err = a.Profiles(ctx, 4, 10)
+	assert.True(t, errors.Is(err, memberclicks.ErrBadRequest))
+
+	_, err = a.Profile(ctx, "1")
+	assert.True(t, errors.Is(err, memberclicks.ErrNotFound))
+}
+
+func TestServerProfileSearch(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	a := newAPI(srv)
+	assert.NoError(t, a.Auth(ctx))
+
+	search, err := a.CreateProfileSearch(ctx, map[string]string{"[Group]": "Board"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	resp, err := a.GetProfileSearch(ctx, search)
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Profiles, 1) {
+		assert.Equal(t, "1002583186", resp.Profiles[0].GetID())
+	}
+
+	srv.ExpireSearches()
+	_, err = a.ProfileSearch(ctx, search.ID, 1)
+	assert.True(t, errors.Is(err, memberclicks.ErrNotFound))
+}
+
+func TestServerFaults(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	a := newAPI(srv)
+	a.RetryPolicy = &memberclicks.Backoff{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	assert.NoError(t, a.Auth(ctx))
+
+	srv.Inject(memberclickstest.Fault{Path: "/api/v1/group", StatusCode: http.StatusServiceUnavailable, Times: 2})
+	_, err := a.Groups(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, srv.Requests("GET /api/v1/group"))
+
+	srv.Inject(memberclickstest.Fault{Path: "/api/v1/event", StatusCode: http.StatusTooManyRequests})
+	_, err = a.Events(ctx)
+	assert.True(t, errors.Is(err, memberclicks.ErrRateLimited))
+	srv.ClearFaults()
+	_, err = a.Events(ctx)
+	assert.NoError(t, err)
+}
+
+func TestServerExpireTokens(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	a := newAPI(srv)
+	assert.NoError(t, a.Auth(ctx))
+	srv.ExpireTokens()
+
+	// The client gets a new token after the 401 and retries
+	_, err := a.Countries(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, srv.Requests("GET /api/v1/country"))
+	assert.Equal(t, 2, srv.Requests("POST /oauth/v1/token"))
+}
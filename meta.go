@@ -0,0 +1,74 @@
+package memberclicks
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type metaKey struct{}
+
+// ResponseMeta is the metadata of the response to a call to the API
+type ResponseMeta struct {
+	StatusCode int
+	Header     http.Header
+	// RequestID is the X-Request-Id response header, if the server sent one
+	RequestID string
+	RateLimit RateLimit
+	// Latency is how long the call took, including all attempts
+	Latency time.Duration
+	// Attempts is how many times the request was sent
+	Attempts int
+}
+
+// RateLimit is the rate limit status from the X-RateLimit-* response headers.
+// The fields are zero if the server didn't send them.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// WithResponseMeta returns a context which records the metadata of the response to
+// calls made with it into meta. All fields describe the last request, including its
+// Attempts and Latency, so for calls which fetch multiple pages meta describes the last
+// page. Token renewals aren't recorded, and neither are calls answered from the Cache
+// without a request, which leave meta as is. A meta should only be used by one call at a time.
+func WithResponseMeta(ctx context.Context, meta *ResponseMeta) context.Context {
+	return context.WithValue(ctx, metaKey{}, meta)
+}
+
+func responseMetaFrom(ctx context.Context) *ResponseMeta {
+	meta, _ := ctx.Value(metaKey{}).(*ResponseMeta)
+	return meta
+}
+
+// record stores the metadata of resp, leaving Attempts and Latency to the caller
+func (m *ResponseMeta) record(resp *http.Response) {
+	m.StatusCode = resp.StatusCode
+	m.Header = resp.Header
+	m.RequestID = resp.Header.Get("X-Request-Id")
+	m.RateLimit = RateLimit{
+		Limit:     headerInt(resp.Header, "X-RateLimit-Limit"),
+		Remaining: headerInt(resp.Header, "X-RateLimit-Remaining"),
+	}
+	if reset := headerInt(resp.Header, "X-RateLimit-Reset"); reset > 0 {
+		m.RateLimit.Reset = time.Unix(int64(reset), 0)
+	}
+}
+
+// recordShared stores the metadata of a response shared with other callers, leaving
+// Attempts and Latency to the caller. If there was no response, m is left as is.
+func (m *ResponseMeta) recordShared(s *ResponseMeta) {
+	if s.StatusCode == 0 {
+		return
+	}
+	m.StatusCode, m.Header, m.RequestID, m.RateLimit = s.StatusCode, s.Header.Clone(), s.RequestID, s.RateLimit
+}
+
+func headerInt(h http.Header, key string) int {
+	i, _ := strconv.Atoi(h.Get(key))
+	return i
+}
@@ -0,0 +1,72 @@
+package memberclicks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseMeta(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-1")
+		w.Header().Set("X-RateLimit-Limit", "100")
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("X-RateLimit-Reset", "1507939200")
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"groups":[]}`))
+	}))
+	defer srv.Close()
+
+	a := newAPI(t, WithBaseURL(srv.URL))
+	a.RetryPolicy = &Backoff{MaxAttempts: 2}
+
+	var meta ResponseMeta
+	_, err := a.Groups(WithResponseMeta(ctx, &meta))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, meta.StatusCode)
+	assert.Equal(t, "req-1", meta.RequestID)
+	assert.Equal(t, RateLimit{Limit: 100, Remaining: 42, Reset: meta.RateLimit.Reset}, meta.RateLimit)
+	assert.Equal(t, 2017, meta.RateLimit.Reset.Year())
+	assert.Equal(t, 2, meta.Attempts)
+	assert.True(t, meta.Latency > 0)
+	assert.Equal(t, "100", meta.Header.Get("X-RateLimit-Limit"))
+
+	// Calls without a recorder work as before.
+	_, err = a.Groups(ctx)
+	assert.NoError(t, err)
+}
+
+func TestResponseMetaReuse(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Request-Id", r.URL.Path)
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	a := newAPI(t, WithBaseURL(srv.URL))
+	a.RetryPolicy = &Backoff{MaxAttempts: 2}
+
+	var meta ResponseMeta
+	mctx := WithResponseMeta(ctx, &meta)
+	assert.NoError(t, a.Get(mctx, "/api/v1/group", nil))
+	assert.Equal(t, 2, meta.Attempts)
+	assert.Equal(t, "/api/v1/group", meta.RequestID)
+
+	// The second call overwrites every field, Attempts included
+	assert.NoError(t, a.Get(mctx, "/api/v1/event", nil))
+	assert.Equal(t, 1, meta.Attempts)
+	assert.Equal(t, 200, meta.StatusCode)
+	assert.Equal(t, "/api/v1/event", meta.RequestID)
+}
@@ -0,0 +1,68 @@
+package memberclicks
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client labels of the metrics
+const (
+	MetricsClientAPI     = "api"
+	MetricsClientClassic = "classic"
+)
+
+var (
+	uuidSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// Metrics collects metrics about the requests of API and classic clients. The prommetrics
+// package implements it with Prometheus.
+type Metrics interface {
+	// ObserveRequest records a request and its response. A statusCode of 0 means no
+	// response was received.
+	ObserveRequest(client string, req *http.Request, statusCode int, d time.Duration, bytes int)
+	// ObserveRetry records a retry of req
+	ObserveRetry(client string, req *http.Request)
+	// ObserveRateLimitWait records time spent waiting on the rate limiter
+	ObserveRateLimitWait(client string, d time.Duration)
+	// ObserveTokenRefresh records an access token renewal
+	ObserveTokenRefresh(err error)
+}
+
+// nopMetrics is used by clients without Metrics
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveRequest(string, *http.Request, int, time.Duration, int) {}
+func (nopMetrics) ObserveRetry(string, *http.Request)                            {}
+func (nopMetrics) ObserveRateLimitWait(string, time.Duration)                    {}
+func (nopMetrics) ObserveTokenRefresh(error)                                     {}
+
+func (a *API) metrics() Metrics {
+	if a.Metrics == nil {
+		return nopMetrics{}
+	}
+	return a.Metrics
+}
+
+// NormalizeEndpoint replaces the IDs in an URL path with {id}, so they can be used as
+// metric labels. For example /api/v1/profile/1002583186 becomes /api/v1/profile/{id}.
+func NormalizeEndpoint(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if seg == "" {
+			continue
+		}
+		if _, err := strconv.ParseInt(seg, 10, 64); err == nil || uuidSegment.MatchString(seg) {
+			segments[i] = "{id}"
+			continue
+		}
+		// Anything but the named sub resources after a profile or user is an ID
+		if i > 0 && (segments[i-1] == "profile" || segments[i-1] == "user") && seg != "me" && seg != "search" {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,17 @@
+package memberclicks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEndpoint(t *testing.T) {
+	assert.Equal(t, "/api/v1/profile", NormalizeEndpoint("/api/v1/profile"))
+	assert.Equal(t, "/api/v1/profile/{id}", NormalizeEndpoint("/api/v1/profile/1002583186"))
+	assert.Equal(t, "/api/v1/profile/{id}", NormalizeEndpoint("/api/v1/profile/foobar"))
+	assert.Equal(t, "/api/v1/profile/me", NormalizeEndpoint("/api/v1/profile/me"))
+	assert.Equal(t, "/api/v1/profile/search", NormalizeEndpoint("/api/v1/profile/search"))
+	assert.Equal(t, "/api/v1/search/{id}", NormalizeEndpoint("/api/v1/search/0b5b1e5c-7d0f-4a4e-9f2a-3c1d2e3f4a5b"))
+	assert.Equal(t, "/services/user/{id}", NormalizeEndpoint("/services/user/12345678"))
+}
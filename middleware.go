@@ -0,0 +1,64 @@
+package memberclicks
+
+import (
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Handler sends a single attempt of a request and decodes the response into result
+type Handler func(ctx context.Context, req *http.Request, result interface{}) error
+
+// Middleware wraps a Handler to run code around every request the API sends,
+// including OAuth token requests. The request it sees has all headers set,
+// including Authorization.
+type Middleware func(next Handler) Handler
+
+// Use adds middlewares to the client. The first middleware added is the outermost.
+func (a *API) Use(mw ...Middleware) *API {
+	a.Lock()
+	a.middleware = append(a.middleware, mw...)
+	a.Unlock()
+	return a
+}
+
+// handler returns the send Handler wrapped in the client's middlewares
+func (a *API) handler() Handler {
+	a.RLock()
+	defer a.RUnlock()
+	h := Handler(a.send)
+	for i := len(a.middleware) - 1; i >= 0; i-- {
+		h = a.middleware[i](h)
+	}
+	return h
+}
+
+// LogRequests returns a Middleware which logs the method, path, duration and error
+// of every request with logf, for example log.Printf.
+func LogRequests(logf func(format string, v ...interface{})) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *http.Request, result interface{}) error {
+			start := time.Now()
+			err := next(ctx, req, result)
+			if err != nil {
+				logf("memberclicks: %s %s %v: %v", req.Method, req.URL.Path, time.Since(start), err)
+			} else {
+				logf("memberclicks: %s %s %v", req.Method, req.URL.Path, time.Since(start))
+			}
+			return err
+		}
+	}
+}
+
+// SetHeaders returns a Middleware which sets the given headers on every request
+func SetHeaders(h http.Header) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *http.Request, result interface{}) error {
+			for k, v := range h {
+				req.Header[http.CanonicalHeaderKey(k)] = v
+			}
+			return next(ctx, req, result)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package memberclicks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/v1/token" {
+			fmt.Fprint(w, `{"access_token":"abc"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"name":%q}`, r.Header.Get("X-Tenant"))
+	}))
+	defer srv.Close()
+
+	var order, auth []string
+	var logs []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, req *http.Request, result interface{}) error {
+				order = append(order, name)
+				auth = append(auth, req.Header.Get("Authorization"))
+				return next(ctx, req, result)
+			}
+		}
+	}
+	logf := func(format string, v ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, v...))
+	}
+
+	a := newAPI(t, WithBaseURL(srv.URL))
+	a.Use(trace("outer"), trace("inner"), SetHeaders(http.Header{"x-tenant": {"chapter"}}), LogRequests(logf))
+	assert.NoError(t, a.Auth(ctx))
+
+	var g Group
+	assert.NoError(t, a.Get(ctx, "/api/v1/group/1", &g))
+	assert.Equal(t, "chapter", g.Name)
+	assert.Equal(t, []string{"outer", "inner", "outer", "inner"}, order)
+	assert.Equal(t, "Basic aWQ6c2VjcmV0", auth[0])
+	assert.Equal(t, "Bearer abc", auth[2])
+	assert.Len(t, logs, 2)
+	assert.Contains(t, logs[1], "memberclicks: GET /api/v1/group/1")
+
+	// Middlewares can fail requests without sending them.
+	fault := errors.New("injected")
+	a.Use(func(next Handler) Handler {
+		return func(ctx context.Context, req *http.Request, result interface{}) error {
+			return fault
+		}
+	})
+	assert.Equal(t, fault, a.Get(ctx, "/api/v1/group/1", &g))
+	assert.Contains(t, logs[2], "injected")
+}
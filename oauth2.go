@@ -0,0 +1,117 @@
+package memberclicks
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+)
+
+var (
+	_ oauth2.TokenSource = (*apiTokenSource)(nil)
+)
+
+// Endpoint returns the OAuth2 endpoint of the MemberClicks organization
+func Endpoint(orgID string) oauth2.Endpoint {
+	return endpoint(OrgURL(orgID))
+}
+
+func endpoint(prefix string) oauth2.Endpoint {
+	return oauth2.Endpoint{
+		AuthURL:   prefix + "/oauth/v1/authorize",
+		TokenURL:  prefix + "/oauth/v1/token",
+		AuthStyle: oauth2.AuthStyleInHeader,
+	}
+}
+
+// OAuth2Config returns an *oauth2.Config for the client's organization and credentials
+func (a *API) OAuth2Config(redirectURL string, scopes ...string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     a.clientID,
+		ClientSecret: a.clientSecret,
+		Endpoint:     endpoint(a.baseURL()),
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+	}
+}
+
+// OAuth2TokenSource returns an oauth2.TokenSource with the client's own access token.
+// If the client has no access token yet, it authenticates with Auth first. The token
+// is renewed the same way it would be for the client's own requests.
+func (a *API) OAuth2TokenSource(ctx context.Context) oauth2.TokenSource {
+	return &apiTokenSource{ctx: ctx, api: a}
+}
+
+type apiTokenSource struct {
+	ctx context.Context
+	api *API
+}
+
+// Token implements the oauth2.TokenSource interface
+func (s *apiTokenSource) Token() (*oauth2.Token, error) {
+	if s.api.Token() == nil {
+		if err := s.api.Auth(s.ctx); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := s.api.validAccessToken(s.ctx); err != nil {
+		return nil, err
+	}
+	return s.api.Token().OAuth2(), nil
+}
+
+// OAuth2 converts the token to an *oauth2.Token. The MemberClicks specific fields
+// are available with the Extra method of the returned token.
+func (t *Token) OAuth2() *oauth2.Token {
+	tok := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		RefreshToken: t.RefreshToken,
+		Expiry:       t.Expiry,
+		ExpiresIn:    t.ExpiresIn,
+	}
+	return tok.WithExtra(map[string]interface{}{
+		"scope":     t.Scope,
+		"serviceId": t.ServiceID,
+		"userId":    t.UserID,
+		"jti":       t.JTI,
+	})
+}
+
+// TokenFromOAuth2 converts an *oauth2.Token to a *Token, including the MemberClicks
+// specific fields from the extra token response data.
+func TokenFromOAuth2(t *oauth2.Token) *Token {
+	tok := &Token{
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		RefreshToken: t.RefreshToken,
+		ExpiresIn:    t.ExpiresIn,
+		Expiry:       t.Expiry,
+		ServiceID:    extraInt(t.Extra("serviceId")),
+		UserID:       extraInt(t.Extra("userId")),
+	}
+	tok.Scope, _ = t.Extra("scope").(string)
+	tok.JTI, _ = t.Extra("jti").(string)
+	return tok
+}
+
+// extraInt converts an extra token value to an int64. Values decoded from a JSON
+// token response are float64s, values set with WithExtra can be anything.
+func extraInt(val interface{}) int64 {
+	switch v := val.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case json.Number:
+		i, _ := v.Int64()
+		return i
+	case string:
+		i, _ := strconv.ParseInt(v, 10, 64)
+		return i
+	}
+	return 0
+}
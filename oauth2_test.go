@@ -0,0 +1,59 @@
+package memberclicks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestOAuth2Config(t *testing.T) {
+	a := newAPI(t)
+	c := a.OAuth2Config("https://example.com/callback", ScopeRead)
+	assert.Equal(t, "https://demo.memberclicks.net/oauth/v1/authorize", c.Endpoint.AuthURL)
+	assert.Equal(t, "https://demo.memberclicks.net/oauth/v1/token", c.Endpoint.TokenURL)
+	assert.Equal(t, "id", c.ClientID)
+	assert.Equal(t, []string{"read"}, c.Scopes)
+	assert.Contains(t, c.AuthCodeURL("state"), "redirect_uri=https%3A%2F%2Fexample.com%2Fcallback")
+}
+
+func TestTokenOAuth2(t *testing.T) {
+	tok := &Token{AccessToken: "a", TokenType: "bearer", RefreshToken: "r", ExpiresIn: 3600, Expiry: time.Now(), Scope: "read", ServiceID: 12, UserID: 34, JTI: "j"}
+	assert.Equal(t, tok, TokenFromOAuth2(tok.OAuth2()))
+
+	// Extra values decoded from JSON are float64s.
+	var raw map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"serviceId":12,"userId":34,"jti":"j"}`), &raw))
+	res := TokenFromOAuth2((&oauth2.Token{AccessToken: "a"}).WithExtra(raw))
+	assert.Equal(t, int64(12), res.ServiceID)
+	assert.Equal(t, int64(34), res.UserID)
+	assert.Equal(t, "j", res.JTI)
+}
+
+func TestAPITokenSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/v1/token" {
+			fmt.Fprint(w, `{"access_token":"abc","expires_in":3600,"serviceId":5}`)
+			return
+		}
+		fmt.Fprintf(w, `{"authorization":%q}`, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	a := newAPI(t, WithHTTPClient(&http.Client{Transport: &hostTransport{srv.URL}}))
+	tok, err := a.OAuth2TokenSource(ctx).Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", tok.AccessToken)
+	assert.Equal(t, int64(5), extraInt(tok.Extra("serviceId")))
+
+	b := newAPI(t, WithHTTPClient(a.Client))
+	b.TokenSource = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "xyz"})
+	var res map[string]string
+	assert.NoError(t, b.Get(ctx, "/api/v1/group", &res))
+	assert.Equal(t, "Bearer xyz", res["authorization"])
+}
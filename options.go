@@ -0,0 +1,96 @@
+package memberclicks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNoOrgID is returned by New for a blank organization ID
+var ErrNoOrgID = errors.New("memberclicks: organization ID is required")
+
+// Option configures an API client created with New
+type Option func(a *API) error
+
+// WithHTTPClient sets the HTTP client requests are sent with
+func WithHTTPClient(c *http.Client) Option {
+	return func(a *API) error {
+		if c == nil {
+			return errors.New("memberclicks: HTTP client is nil")
+		}
+		a.Client = c
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of requests, instead of the package level Timeout
+func WithTimeout(d time.Duration) Option {
+	return func(a *API) error {
+		if d <= 0 {
+			return fmt.Errorf("memberclicks: invalid timeout %v", d)
+		}
+		a.Timeout = d
+		return nil
+	}
+}
+
+// WithBaseURL sends requests to baseURL instead of the organization's own URL
+func WithBaseURL(baseURL string) Option {
+	return func(a *API) error {
+		if err := validBaseURL(baseURL); err != nil {
+			return err
+		}
+		a.BaseURL = baseURL
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header of requests
+func WithUserAgent(userAgent string) Option {
+	return func(a *API) error {
+		a.UserAgent = userAgent
+		return nil
+	}
+}
+
+// WithAccessToken uses the access token for requests, like SetAccessToken
+func WithAccessToken(accessToken string) Option {
+	return func(a *API) error {
+		if accessToken == "" {
+			return errors.New("memberclicks: access token is blank")
+		}
+		a.accessToken = accessToken
+		return nil
+	}
+}
+
+// WithScopes sets the scopes Auth requests
+func WithScopes(scopes ...string) Option {
+	return func(a *API) error {
+		a.Scopes = scopes
+		return nil
+	}
+}
+
+// validBaseURL checks that baseURL is an absolute HTTP URL
+func validBaseURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("memberclicks: invalid base URL: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("memberclicks: base URL %q is not an absolute HTTP URL", baseURL)
+	}
+	return nil
+}
+
+// scope returns the scope Auth requests
+func (a *API) scope() string {
+	if len(a.Scopes) == 0 {
+		return ScopeRead
+	}
+	return strings.Join(a.Scopes, " ")
+}
@@ -0,0 +1,46 @@
+package memberclicks
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+
+	"golang.org/x/net/context"
+)
+
+// CodeChallengeMethodS256 is the code challenge method of CodeChallengeS256
+const CodeChallengeMethodS256 = "S256"
+
+// NewCodeVerifier returns a random PKCE code verifier for the authorization code flow of
+// public clients, like single page and mobile apps. Keep it until the code is exchanged
+// with GetTokenWithPKCE, and send its CodeChallengeS256 to the authorize page.
+func NewCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallengeS256 returns the S256 code challenge of the code verifier
+func CodeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// GetAuthCodeURLWithPKCE is GetAuthCodeURL with the S256 code challenge of the code verifier
+func (a *API) GetAuthCodeURLWithPKCE(scope, state, redirectURL, verifier string) string {
+	return a.authorizeURL("code", scope, state, redirectURL, url.Values{
+		"code_challenge":        {CodeChallengeS256(verifier)},
+		"code_challenge_method": {CodeChallengeMethodS256},
+	})
+}
+
+// GetTokenWithPKCE trades an auth code from GetAuthCodeURLWithPKCE for an access token,
+// proving with the code verifier that the client requested the code. Clients without a
+// client secret send their client ID only.
+func (a *API) GetTokenWithPKCE(ctx context.Context, authCode, scope, state, redirectURL, verifier string) (*Token, error) {
+	ctx = context.WithValue(withOperation(ctx, "GetTokenWithPKCE"), publicClientKey{}, true)
+	return a.exchangeCode(ctx, authCode, scope, state, redirectURL, url.Values{"code_verifier": {verifier}})
+}
@@ -0,0 +1,118 @@
+package memberclicks
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+
+	"github.com/bradberger/go-memberclicks/memberclickstest"
+)
+
+func TestCodeChallengeS256(t *testing.T) {
+	v1, err := NewCodeVerifier()
+	assert.NoError(t, err)
+	// Same as the oauth2 package, so OAuth2Config can be used with the verifiers too
+	assert.Equal(t, oauth2.S256ChallengeFromVerifier(v1), CodeChallengeS256(v1))
+	v2, _ := NewCodeVerifier()
+	assert.Len(t, v1, 43)
+	assert.NotEqual(t, v1, v2)
+	assert.Regexp(t, "^[A-Za-z0-9_-]+$", v1)
+}
+
+func TestAuthCodeURLEscaping(t *testing.T) {
+	a := newAPI(t, WithBaseURL("http://127.0.0.1:8080"))
+	for _, raw := range []string{
+		a.GetAuthCodeURL("read write", "a&b=c", "http://localhost/callback?x=1&y=2"),
+		a.GetAuthCodeURLWithPKCE("read write", "a&b=c", "http://localhost/callback?x=1&y=2", "verifier"),
+		a.GetAuthRequestURL("read write", "a&b=c", "http://localhost/callback?x=1&y=2"),
+	} {
+		u, err := url.Parse(raw)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		q := u.Query()
+		assert.Equal(t, "/oauth/v1/authorize", u.Path)
+		assert.Equal(t, "id", q.Get("client_id"))
+		assert.Equal(t, "read write", q.Get("scope"))
+		assert.Equal(t, "a&b=c", q.Get("state"))
+		assert.Equal(t, "http://localhost/callback?x=1&y=2", q.Get("redirect_uri"))
+	}
+
+	u, _ := url.Parse(a.GetAuthCodeURLWithPKCE("read", "xyz", "http://localhost/callback", "verifier"))
+	assert.Equal(t, CodeChallengeS256("verifier"), u.Query().Get("code_challenge"))
+	assert.Equal(t, CodeChallengeMethodS256, u.Query().Get("code_challenge_method"))
+}
+
+// authCode follows the authorize URL to the fake server's redirect and returns the code
+func authCode(t *testing.T, authURL string) string {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	loc, err := resp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc.Query().Get("code")
+}
+
+func TestGetTokenWithPKCE(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	// A public client, without a client secret
+	a, err := New("test", memberclickstest.DefaultClientID, "", WithBaseURL(srv.URL))
+	if !assert.NoError(t, err) {
+		return
+	}
+	verifier, _ := NewCodeVerifier()
+	redirect := "http://localhost/callback"
+
+	code := authCode(t, a.GetAuthCodeURLWithPKCE("read", "xyz", redirect, verifier))
+	tok, err := a.GetTokenWithPKCE(ctx, code, "read", "xyz", redirect, verifier)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, memberclickstest.DefaultProfileID, tok.UserID)
+	}
+
+	code = authCode(t, a.GetAuthCodeURLWithPKCE("read", "xyz", redirect, verifier))
+	_, err = a.GetTokenWithPKCE(ctx, code, "read", "xyz", redirect, "wrong")
+	assert.True(t, errors.Is(err, ErrBadRequest), "the verifier has to match the challenge")
+
+	// Without PKCE, a blank client secret is a missing setting, for every grant
+	code = authCode(t, a.GetAuthCodeURL("read", "xyz", redirect))
+	_, err = a.GetToken(ctx, code, "read", "xyz", redirect)
+	assert.True(t, errors.Is(err, ErrMissingSetting), "public clients have to use PKCE")
+	assert.True(t, errors.Is(a.Auth(ctx), ErrMissingSetting))
+	_, err = a.OwnerPassword(ctx, memberclickstest.DefaultUsername, memberclickstest.DefaultPassword)
+	assert.True(t, errors.Is(err, ErrMissingSetting))
+	assert.NotContains(t, err.Error(), EnvClientSecret, "the client wasn't configured from the environment")
+	assert.Equal(t, 2, srv.Requests("POST /oauth/v1/token"), "only the PKCE exchanges were sent")
+
+	// The tokens of the PKCE exchange are renewed with the refresh token
+	srv.TokenTTL = 30 * time.Second
+	code = authCode(t, a.GetAuthCodeURLWithPKCE("read", "xyz", redirect, verifier))
+	tok, err = a.GetTokenWithPKCE(ctx, code, "read", "xyz", redirect, verifier)
+	if !assert.NoError(t, err) {
+		return
+	}
+	a.SetToken(tok)
+	assert.NoError(t, a.Get(ctx, "/api/v1/group", nil))
+	assert.Equal(t, 4, srv.Requests("POST /oauth/v1/token"))
+	assert.NotEqual(t, tok.AccessToken, a.Token().AccessToken)
+
+	// Confidential clients can use PKCE too
+	c, _ := New("test", memberclickstest.DefaultClientID, memberclickstest.DefaultClientSecret, WithBaseURL(srv.URL))
+	code = authCode(t, c.GetAuthCodeURLWithPKCE("read", "xyz", redirect, verifier))
+	_, err = c.GetToken(ctx, code, "read", "xyz", redirect)
+	assert.Error(t, err, "codes requested with a challenge need the verifier")
+	code = authCode(t, c.GetAuthCodeURLWithPKCE("read", "xyz", redirect, verifier))
+	_, err = c.GetTokenWithPKCE(ctx, code, "read", "xyz", redirect, verifier)
+	assert.NoError(t, err)
+}
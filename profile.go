@@ -166,8 +166,9 @@ func (p *Profile) Save() (list []datastore.Property, err error) {
 // Me returns the profile associated with the accessToken
 func (a *API) Me(ctx context.Context, accessToken string) (*Profile, error) {
 
+	ctx = withOperation(ctx, "Me")
 	var p Profile
-	req, err := http.NewRequest("GET", a.makeURL("/api/v1/profile/me"), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", a.makeURL("/api/v1/profile/me"), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -181,6 +182,7 @@ func (a *API) Me(ctx context.Context, accessToken string) (*Profile, error) {
 
 // Profile retrieves a profile with the given id
 func (a *API) Profile(ctx context.Context, id string) (*Profile, error) {
+	ctx = withOperation(ctx, "Profile")
 	var p Profile
 	if err := a.Get(ctx, "/api/v1/profile/"+id, &p); err != nil {
 		return nil, err
@@ -3,7 +3,11 @@ package memberclicks
 import (
 	"fmt"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks/internal/tracing"
 )
 
 type ProfileResp struct {
@@ -29,12 +33,18 @@ type ProfileSearchResp struct {
 }
 
 // Profiles returns a page of profiles. Set the pageNum to be < 1 to get all pages at the same time.
+// If the context is done before all pages are fetched, the pages so far are returned with the context's error.
+// For large organizations, EachProfile needs far less memory.
 func (a *API) Profiles(ctx context.Context, pageNum, pageSize int) (*ProfileResp, error) {
 
+	ctx = withOperation(ctx, "Profiles")
 	all := pageNum < 1
 	if all {
 		pageSize = 100
 		pageNum = 1
+		var span trace.Span
+		ctx, span = a.startSpan(ctx, "Profiles", tracing.PageSize.Int(pageSize))
+		defer span.End()
 	}
 
 	var resp ProfileResp
@@ -44,6 +54,9 @@ func (a *API) Profiles(ctx context.Context, pageNum, pageSize int) (*ProfileResp
 
 	if all {
 		for i := 1; i < resp.TotalPageCount; i++ {
+			if err := ctx.Err(); err != nil {
+				return &resp, err
+			}
 			pg, err := a.Profiles(ctx, i+1, pageSize)
 			if err != nil {
 				return &resp, err
@@ -63,10 +76,16 @@ func (a *API) Profiles(ctx context.Context, pageNum, pageSize int) (*ProfileResp
 // ProfileSearch returns a profile search with the given ID. If pageNum is less than 1, all pages of the search will be returned.
 func (a *API) ProfileSearch(ctx context.Context, searchID string, pageNum int) (*ProfileResp, error) {
 
+	ctx = withOperation(ctx, "ProfileSearch")
 	all := pageNum < 1
 	if all || pageNum < 1 {
 		pageNum = 1
 	}
+	if all {
+		var span trace.Span
+		ctx, span = a.startSpan(ctx, "ProfileSearch", attribute.String("memberclicks.search_id", searchID))
+		defer span.End()
+	}
 
 	var resp ProfileResp
 	urlStr := fmt.Sprintf("/api/v1/profile?searchId=%s&pageSize=100&pageNumber=%d", searchID, pageNum)
@@ -76,6 +95,9 @@ func (a *API) ProfileSearch(ctx context.Context, searchID string, pageNum int) (
 
 	if all && resp.TotalPageCount > 1 {
 		for i := 1; i < resp.TotalPageCount; i++ {
+			if err := ctx.Err(); err != nil {
+				return &resp, err
+			}
 			pg, err := a.ProfileSearch(ctx, searchID, i+1)
 			if err != nil {
 				return &resp, err
@@ -89,6 +111,7 @@ func (a *API) ProfileSearch(ctx context.Context, searchID string, pageNum int) (
 
 // ProfileSearch returns a profile search with the given ID.
 func (a *API) GetProfileSearch(ctx context.Context, search *ProfileSearchResp) (*ProfileResp, error) {
+	ctx = withOperation(ctx, "GetProfileSearch")
 	var resp ProfileResp
 	if err := a.Get(ctx, search.ProfilesURL, &resp); err != nil {
 		return nil, err
@@ -99,6 +122,7 @@ func (a *API) GetProfileSearch(ctx context.Context, search *ProfileSearchResp) (
 // CreateProfileSearch creates a profile search and returns the resulting search ID.
 // Profile searches exipre every half an hour.
 func (a *API) CreateProfileSearch(ctx context.Context, params interface{}) (*ProfileSearchResp, error) {
+	ctx = withOperation(ctx, "CreateProfileSearch")
 	var resp ProfileSearchResp
 	if err := a.PostJSON(ctx, "/api/v1/profile/search", params, &resp); err != nil {
 		return nil, err
@@ -108,6 +132,7 @@ func (a *API) CreateProfileSearch(ctx context.Context, params interface{}) (*Pro
 
 // ProfilePageCt gets the total number of profiles
 func (a *API) ProfilePageCt(ctx context.Context, pageSize int) (int, error) {
+	ctx = withOperation(ctx, "ProfilePageCt")
 	if pageSize < 10 {
 		pageSize = 10
 	}
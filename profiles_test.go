@@ -1,10 +1,16 @@
 package memberclicks
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
 )
 
 func TestProfiles(t *testing.T) {
@@ -44,3 +50,32 @@ func TestProfileSearch(t *testing.T) {
 	_, err = mc.ProfileSearch(ctx, search.ID, 1)
 	assert.NoError(t, err)
 }
+
+func TestProfilesContext(t *testing.T) {
+	var pages int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pages, 1)
+		fmt.Fprintf(w, `{"totalPageCount":5,"pageNumber":%s,"profiles":[{"[Profile ID]":1}]}`, r.URL.Query().Get("pageNumber"))
+	}))
+	defer srv.Close()
+
+	a := newAPI(t, WithBaseURL(srv.URL))
+
+	cctx, cancel := context.WithCancel(ctx)
+	a.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Query().Get("pageNumber") == "2" {
+			cancel()
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+	resp, err := a.Profiles(cctx, 0, 100)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Len(t, resp.Profiles, 1)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&pages))
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
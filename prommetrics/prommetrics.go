@@ -0,0 +1,107 @@
+// Package prommetrics collects Prometheus metrics about the requests of MemberClicks
+// API and classic clients. Set the Metrics field of the clients to a Metrics from New.
+package prommetrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bradberger/go-memberclicks"
+)
+
+var _ memberclicks.Metrics = (*Metrics)(nil)
+
+// Metrics collects Prometheus metrics about the requests of API and classic clients.
+// One Metrics can be shared by many clients. All methods are safe to call on a nil *Metrics.
+type Metrics struct {
+	requests       *prometheus.CounterVec
+	duration       *prometheus.HistogramVec
+	bytes          *prometheus.CounterVec
+	retries        *prometheus.CounterVec
+	rateLimitWait  *prometheus.CounterVec
+	tokenRefreshes *prometheus.CounterVec
+}
+
+// New creates the metrics and registers them with reg
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "memberclicks_requests_total",
+			Help: "Requests sent to MemberClicks by endpoint and status code.",
+		}, []string{"client", "method", "endpoint", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "memberclicks_request_duration_seconds",
+			Help:    "Latency of requests sent to MemberClicks.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"client", "method", "endpoint"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "memberclicks_response_bytes_total",
+			Help: "Bytes of response bodies received from MemberClicks.",
+		}, []string{"client", "endpoint"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "memberclicks_retries_total",
+			Help: "Requests to MemberClicks which were retried.",
+		}, []string{"client", "method", "endpoint"}),
+		rateLimitWait: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "memberclicks_rate_limit_wait_seconds_total",
+			Help: "Time spent waiting on the client side rate limiter.",
+		}, []string{"client"}),
+		tokenRefreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "memberclicks_token_refreshes_total",
+			Help: "Access token renewals by result.",
+		}, []string{"result"}),
+	}
+	for _, c := range []prometheus.Collector{m.requests, m.duration, m.bytes, m.retries, m.rateLimitWait, m.tokenRefreshes} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ObserveRequest records a request and its response. A statusCode of 0 means no
+// response was received.
+func (m *Metrics) ObserveRequest(client string, req *http.Request, statusCode int, d time.Duration, bytes int) {
+	if m == nil {
+		return
+	}
+	endpoint := memberclicks.NormalizeEndpoint(req.URL.Path)
+	status := "error"
+	if statusCode > 0 {
+		status = strconv.Itoa(statusCode)
+	}
+	m.requests.WithLabelValues(client, req.Method, endpoint, status).Inc()
+	m.duration.WithLabelValues(client, req.Method, endpoint).Observe(d.Seconds())
+	m.bytes.WithLabelValues(client, endpoint).Add(float64(bytes))
+}
+
+// ObserveRetry records a retry of req
+func (m *Metrics) ObserveRetry(client string, req *http.Request) {
+	if m == nil {
+		return
+	}
+	m.retries.WithLabelValues(client, req.Method, memberclicks.NormalizeEndpoint(req.URL.Path)).Inc()
+}
+
+// ObserveRateLimitWait records time spent waiting on the rate limiter
+func (m *Metrics) ObserveRateLimitWait(client string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.rateLimitWait.WithLabelValues(client).Add(d.Seconds())
+}
+
+// ObserveTokenRefresh records an access token renewal
+func (m *Metrics) ObserveTokenRefresh(err error) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.tokenRefreshes.WithLabelValues(result).Inc()
+}
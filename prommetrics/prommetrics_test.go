@@ -0,0 +1,68 @@
+package prommetrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks"
+)
+
+var ctx = context.Background()
+
+func TestMetrics(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/v1/token" {
+			fmt.Fprint(w, `{"access_token":"abc","expires_in":1}`)
+			return
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"[Profile ID]":1}`)
+	}))
+	defer srv.Close()
+
+	reg := prometheus.NewRegistry()
+	m, err := New(reg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = New(reg)
+	assert.Error(t, err)
+
+	a, _ := memberclicks.New("demo", "id", "secret", memberclicks.WithBaseURL(srv.URL))
+	a.Metrics = m
+	a.Limiter = memberclicks.NewLimiter(1000, 10)
+	a.RetryPolicy = &memberclicks.Backoff{MaxAttempts: 2}
+	assert.NoError(t, a.Auth(ctx))
+	for _, id := range []string{"1", "2"} {
+		_, err := a.Profile(ctx, id)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues("api", "GET", "/api/v1/profile/{id}", "502")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.requests.WithLabelValues("api", "GET", "/api/v1/profile/{id}", "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.retries.WithLabelValues("api", "GET", "/api/v1/profile/{id}")))
+	assert.Equal(t, float64(2*len(`{"[Profile ID]":1}`)), testutil.ToFloat64(m.bytes.WithLabelValues("api", "/api/v1/profile/{id}")))
+	assert.True(t, testutil.ToFloat64(m.tokenRefreshes.WithLabelValues("success")) >= 1)
+
+	// One series for the token requests, one for the profile requests.
+	count, err := testutil.GatherAndCount(reg, "memberclicks_request_duration_seconds")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	assert.NotPanics(t, func() {
+		var nilMetrics *Metrics
+		nilMetrics.ObserveTokenRefresh(nil)
+	})
+}
@@ -0,0 +1,24 @@
+package memberclicks
+
+import (
+	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
+)
+
+var (
+	_ Limiter = (*rate.Limiter)(nil)
+)
+
+// Limiter limits how fast requests are sent. Every attempt at a request waits on the
+// Limiter first. A *rate.Limiter from golang.org/x/time/rate implements it.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// NewLimiter returns a token bucket Limiter which allows rps requests per second on
+// average with bursts of up to burst requests. The Limiter is safe for concurrent use,
+// so to stay under the rate limit of an organization share it between all the clients
+// using the same credentials.
+func NewLimiter(rps float64, burst int) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(rps), burst)
+}
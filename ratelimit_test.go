@@ -0,0 +1,34 @@
+package memberclicks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestLimiter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	l := NewLimiter(20, 1)
+	a, b := newAPI(t), newAPI(t)
+	a.Limiter, b.Limiter = l, l
+
+	start := time.Now()
+	for _, c := range []*API{a, b, a, b} {
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		assert.NoError(t, c.Do(ctx, req, &struct{}{}))
+	}
+	assert.True(t, time.Since(start) >= 140*time.Millisecond)
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	assert.Error(t, a.Do(cctx, req, &struct{}{}))
+}
@@ -0,0 +1,256 @@
+// Package registry manages the API and classic clients of many MemberClicks
+// organizations, for example the chapters of an association.
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks"
+	"github.com/bradberger/go-memberclicks/classic"
+)
+
+var (
+	// ErrUnknownOrg is returned for names which aren't in the registry
+	ErrUnknownOrg = errors.New("registry: unknown organization")
+
+	// ErrNoClassic is returned by Classic for organizations without classic credentials
+	ErrNoClassic = errors.New("registry: organization has no classic API credentials")
+)
+
+// Org is the configuration of an organization. The credentials are a memberclicks.Config,
+// so their secrets can be read from files too.
+type Org struct {
+	// Name identifies the organization in the registry
+	Name string `json:"name" yaml:"name" toml:"name"`
+
+	memberclicks.Config `yaml:",inline"`
+
+	// RateLimit is the number of requests per second to the organization, shared by both
+	// APIs, with bursts of up to Burst requests. If 0, requests aren't limited, other
+	// than by a Limiter set by the Options.
+	RateLimit float64 `json:"rateLimit,omitempty" yaml:"rateLimit,omitempty" toml:"rateLimit,omitempty"`
+	Burst     int     `json:"burst,omitempty" yaml:"burst,omitempty" toml:"burst,omitempty"`
+}
+
+// Config is the file format of Load and LoadFile
+type Config struct {
+	Orgs []Org `json:"orgs" yaml:"orgs" toml:"orgs"`
+}
+
+// Registry holds the clients of many organizations by name. The clients are created and
+// authenticated the first time they're used. The API clients renew their tokens themselves.
+type Registry struct {
+	// Options are applied to every API client, and ClassicOptions to every classic client
+	Options        []memberclicks.Option
+	ClassicOptions []classic.Option
+
+	// Concurrency is how many organizations Each works on at the same time. If 0, all of them.
+	Concurrency int
+
+	mu   sync.RWMutex
+	orgs map[string]*entry
+}
+
+type entry struct {
+	org     Org
+	limiter memberclicks.Limiter
+
+	mu      sync.Mutex
+	api     *memberclicks.API
+	classic *classic.Client
+}
+
+// New returns a registry with the organizations
+func New(orgs ...Org) (*Registry, error) {
+	r := &Registry{orgs: map[string]*entry{}}
+	for _, o := range orgs {
+		if err := r.Add(o); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Load returns a registry with the organizations of the JSON encoded Config read from rd
+func Load(rd io.Reader) (*Registry, error) {
+	var c Config
+	if err := json.NewDecoder(rd).Decode(&c); err != nil {
+		return nil, fmt.Errorf("registry: invalid config: %v", err)
+	}
+	return New(c.Orgs...)
+}
+
+// LoadFile returns a registry with the organizations of the Config file at path, in any
+// format memberclicks.LoadConfig supports
+func LoadFile(path string) (*Registry, error) {
+	var c Config
+	if err := memberclicks.UnmarshalConfigFile(path, &c); err != nil {
+		return nil, err
+	}
+	return New(c.Orgs...)
+}
+
+// Add adds an organization. Names have to be unique.
+func (r *Registry) Add(o Org) error {
+	if o.Name == "" {
+		return errors.New("registry: organization name is required")
+	}
+	if o.OrgID == "" {
+		return fmt.Errorf("registry: %s: %w", o.Name, memberclicks.ErrNoOrgID)
+	}
+	if err := o.ReadSecretFiles(); err != nil {
+		return fmt.Errorf("registry: %s: %w", o.Name, err)
+	}
+	e := &entry{org: o}
+	if o.RateLimit > 0 {
+		burst := o.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		e.limiter = memberclicks.NewLimiter(o.RateLimit, burst)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.orgs == nil {
+		r.orgs = map[string]*entry{}
+	}
+	if _, ok := r.orgs[o.Name]; ok {
+		return fmt.Errorf("registry: duplicate organization %s", o.Name)
+	}
+	r.orgs[o.Name] = e
+	return nil
+}
+
+// Names returns the names of the organizations in order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.orgs))
+	for name := range r.orgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *Registry) entry(name string) (*entry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.orgs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownOrg, name)
+	}
+	return e, nil
+}
+
+// API returns the authenticated API client of the organization. If the authentication
+// fails, the next call tries again.
+func (r *Registry) API(ctx context.Context, name string) (*memberclicks.API, error) {
+	e, err := r.entry(name)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.api != nil {
+		return e.api, nil
+	}
+
+	a, err := memberclicks.NewFromConfig(&e.org.Config, r.Options...)
+	if err != nil {
+		return nil, fmt.Errorf("registry: %s: %w", name, err)
+	}
+	if e.limiter != nil {
+		a.Limiter = e.limiter
+	}
+	if err := a.Auth(ctx); err != nil {
+		return nil, fmt.Errorf("registry: %s: %w", name, err)
+	}
+	e.api = a
+	return a, nil
+}
+
+// Classic returns the authenticated classic client of the organization. If the
+// authentication fails, the next call tries again.
+func (r *Registry) Classic(ctx context.Context, name string) (*classic.Client, error) {
+	e, err := r.entry(name)
+	if err != nil {
+		return nil, err
+	}
+	if e.org.APIKey == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNoClassic, name)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.classic != nil {
+		return e.classic, nil
+	}
+
+	opts := append(r.ClassicOptions[:len(r.ClassicOptions):len(r.ClassicOptions)], classic.WithoutAuth())
+	c, err := classic.NewFromConfig(ctx, &e.org.Config, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("registry: %s: %w", name, err)
+	}
+	if e.limiter != nil {
+		c.Limiter = e.limiter
+	}
+	if err := c.Auth(ctx); err != nil {
+		return nil, fmt.Errorf("registry: %s: %w", name, err)
+	}
+	e.classic = c
+	return c, nil
+}
+
+// Result is the result of Each for an organization
+type Result struct {
+	Value interface{}
+	Err   error
+}
+
+// Each calls fn with the API client of every organization concurrently and returns the
+// results by organization name. Organizations whose client can't be authenticated get
+// the error as their result, without fn being called.
+func (r *Registry) Each(ctx context.Context, fn func(ctx context.Context, name string, a *memberclicks.API) (interface{}, error)) map[string]Result {
+	names := r.Names()
+	results := make(map[string]Result, len(names))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	concurrency := r.Concurrency
+	if concurrency < 1 {
+		concurrency = len(names)
+	}
+	sem := make(chan struct{}, concurrency)
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			var res Result
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				a, err := r.API(ctx, name)
+				if err != nil {
+					res.Err = err
+				} else {
+					res.Value, res.Err = fn(ctx, name, a)
+				}
+			case <-ctx.Done():
+				res.Err = ctx.Err()
+			}
+			mu.Lock()
+			results[name] = res
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+	return results
+}
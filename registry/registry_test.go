@@ -0,0 +1,160 @@
+package registry
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks"
+	"github.com/bradberger/go-memberclicks/classic/classictest"
+	"github.com/bradberger/go-memberclicks/memberclickstest"
+)
+
+var ctx = context.Background()
+
+func testOrg(name, baseURL string) Org {
+	return Org{Name: name, Config: memberclicks.Config{
+		OrgID:        name,
+		ClientID:     memberclickstest.DefaultClientID,
+		ClientSecret: memberclickstest.DefaultClientSecret,
+		BaseURL:      baseURL,
+	}}
+}
+
+func TestRegistry(t *testing.T) {
+	north, south := memberclickstest.NewServer(nil), memberclickstest.NewServer(nil)
+	defer north.Close()
+	defer south.Close()
+
+	broken := testOrg("broken", south.URL)
+	broken.ClientSecret = "wrong"
+	r, err := New(testOrg("north", north.URL), testOrg("south", south.URL), broken)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"broken", "north", "south"}, r.Names())
+	assert.Equal(t, 0, north.Requests("POST /oauth/v1/token"), "clients authenticate lazily")
+
+	res := r.Each(ctx, func(ctx context.Context, name string, a *memberclicks.API) (interface{}, error) {
+		return a.Groups(ctx)
+	})
+	assert.Len(t, res, 3)
+	assert.NoError(t, res["north"].Err)
+	assert.Len(t, res["north"].Value.(memberclicks.Groups), 2)
+	assert.NoError(t, res["south"].Err)
+	assert.True(t, errors.Is(res["broken"].Err, memberclicks.ErrUnauthorized))
+
+	a, err := r.API(ctx, "north")
+	assert.NoError(t, err)
+	b, err := r.API(ctx, "north")
+	assert.NoError(t, err)
+	assert.True(t, a == b)
+	assert.Equal(t, 1, north.Requests("POST /oauth/v1/token"))
+
+	_, err = r.API(ctx, "east")
+	assert.True(t, errors.Is(err, ErrUnknownOrg))
+	_, err = r.Classic(ctx, "north")
+	assert.True(t, errors.Is(err, ErrNoClassic))
+
+	assert.Error(t, r.Add(testOrg("north", north.URL)), "names are unique")
+	assert.Error(t, r.Add(Org{Name: "west"}), "org IDs are required")
+}
+
+func TestRegistryRateLimit(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	o := testOrg("north", srv.URL)
+	o.RateLimit, o.Burst = 20, 1
+	r, _ := New(o)
+	a, err := r.API(ctx, "north")
+	if !assert.NoError(t, err) {
+		return
+	}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		_, err := a.Groups(ctx)
+		assert.NoError(t, err)
+	}
+	assert.True(t, time.Since(start) >= 150*time.Millisecond)
+}
+
+func TestRegistryOptionsLimiter(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	l := memberclicks.NewLimiter(100, 1)
+	r, _ := New(testOrg("north", srv.URL))
+	r.Options = []memberclicks.Option{func(a *memberclicks.API) error {
+		a.Limiter = l
+		return nil
+	}}
+	a, err := r.API(ctx, "north")
+	if assert.NoError(t, err) {
+		assert.Equal(t, l, a.Limiter, "the limiter of the options is kept without a RateLimit")
+	}
+}
+
+func TestRegistryClassic(t *testing.T) {
+	srv := classictest.NewServer(nil)
+	defer srv.Close()
+
+	o := testOrg("north", srv.URL)
+	o.APIKey, o.Username, o.Password = classictest.DefaultAPIKey, classictest.DefaultUsername, classictest.DefaultPassword
+	r, _ := New(o)
+	c, err := r.Classic(ctx, "north")
+	if !assert.NoError(t, err) {
+		return
+	}
+	u, err := c.User(ctx, classictest.DefaultUserID)
+	assert.NoError(t, err)
+	assert.Equal(t, classictest.DefaultUserID, u.UserID)
+}
+
+func TestLoad(t *testing.T) {
+	r, err := Load(strings.NewReader(`{"orgs":[{"name":"north","orgId":"north123","clientId":"id","clientSecret":"secret","rateLimit":5}]}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"north"}, r.Names())
+	e, _ := r.entry("north")
+	assert.Equal(t, "north123", e.org.OrgID)
+	assert.NotNil(t, e.limiter)
+
+	_, err = Load(strings.NewReader(`{"orgs":[{"name":"north"}]}`))
+	assert.True(t, errors.Is(err, memberclicks.ErrNoOrgID))
+}
+
+func TestLoadFile(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret")
+	config := filepath.Join(dir, "orgs.json")
+	assert.NoError(t, ioutil.WriteFile(secret, []byte(memberclickstest.DefaultClientSecret+"\n"), 0600))
+	assert.NoError(t, ioutil.WriteFile(config, []byte(`{"orgs":[
+		{"name":"north","orgId":"north","clientId":"`+memberclickstest.DefaultClientID+`","clientSecretFile":"`+secret+`","baseUrl":"`+srv.URL+`"},
+		{"name":"south","orgId":"south","baseUrl":"`+srv.URL+`"}
+	]}`), 0600))
+
+	r, err := LoadFile(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = r.API(ctx, "north")
+	assert.NoError(t, err, "the secret is read from its file")
+	_, err = r.API(ctx, "south")
+	assert.True(t, errors.Is(err, memberclicks.ErrMissingSetting))
+	assert.Contains(t, err.Error(), "south")
+	assert.Contains(t, err.Error(), "missing setting clientId,", "the file key comes first")
+
+	_, err = LoadFile(filepath.Join(dir, "orgs.ini"))
+	assert.Error(t, err)
+}
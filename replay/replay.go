@@ -0,0 +1,296 @@
+// Package replay records HTTP traffic to cassette files and replays it, for deterministic
+// contract tests of MemberClicks clients. Use a Recorder as the transport of the
+// memberclicks.API Client or the classic.Client HttpClient.
+//
+// Credentials, tokens and the RedactFields are redacted before anything is written,
+// so cassettes can be committed.
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bradberger/go-memberclicks/internal/httplog"
+)
+
+// Mode is whether a Recorder records or replays
+type Mode int
+
+// Modes
+const (
+	// ModeReplay answers requests from the cassette and never sends them
+	ModeReplay Mode = iota
+	// ModeRecord sends requests and adds them to the cassette
+	ModeRecord
+)
+
+// EnvRecord is the environment variable which makes ModeFromEnv return ModeRecord
+const EnvRecord = "MEMBERCLICKS_RECORD"
+
+var (
+	// ErrUnmatched is returned in replay mode for requests which aren't in the cassette
+	ErrUnmatched = errors.New("replay: no recorded interaction matches the request")
+
+	_ http.RoundTripper = (*Recorder)(nil)
+)
+
+// ModeFromEnv returns ModeRecord if the MEMBERCLICKS_RECORD environment variable is set, and ModeReplay otherwise
+func ModeFromEnv() Mode {
+	if os.Getenv(EnvRecord) != "" {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// Cassette is the file format of recorded interactions
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper which records to or replays from a cassette file.
+//
+// Requests match recorded ones by method, path, query and body. The host is ignored,
+// query parameters and form fields are compared in any order, and JSON bodies are
+// compared by value. Identical requests are replayed in the order they were recorded,
+// and once they're used up the last one is repeated.
+type Recorder struct {
+	// Transport sends the requests in record mode. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// RedactFields are redacted in addition to the credentials and tokens, for example
+	// the names of profile attributes with personal information.
+	RedactFields []string
+
+	mode     Mode
+	path     string
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// New returns a Recorder for the cassette file at path. In replay mode the cassette has
+// to exist. In record mode it's created or replaced by Save.
+func New(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{mode: mode, path: path}
+	if mode == ModeRecord {
+		return r, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &r.cassette); err != nil {
+		return nil, fmt.Errorf("replay: invalid cassette %s: %v", path, err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// Mode returns whether the recorder records or replays
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Client returns an *http.Client using the recorder as transport
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Save writes the recorded interactions to the cassette file. It does nothing in replay mode.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	b, err := json.MarshalIndent(&r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, b, 0644)
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	rec := Request{
+		Method: req.Method,
+		URL:    r.redactURL(req.URL),
+		Header: httplog.RedactHeader(req.Header),
+		Body:   httplog.RedactBody(body, req.Header.Get("Content-Type"), r.RedactFields...),
+	}
+	if r.mode == ModeRecord {
+		return r.record(req, rec)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return r.replay(req, rec)
+}
+
+// readBody returns the body of req without modifying req, which RoundTrippers must not do.
+// Without GetBody the body can only be read once, so the request returned is then a clone
+// of req with a copy of the body, to send instead.
+func readBody(req *http.Request) (*http.Request, []byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil, nil
+	}
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			req.Body.Close()
+			return nil, nil, err
+		}
+		defer rc.Close()
+		body, err := ioutil.ReadAll(rc)
+		if err != nil {
+			req.Body.Close()
+			return nil, nil, err
+		}
+		return req, body, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return clone, body, nil
+}
+
+func (r *Recorder) record(req *http.Request, rec Request) (*http.Response, error) {
+	t := r.Transport
+	if t == nil {
+		t = http.DefaultTransport
+	}
+	resp, err := t.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Request: rec,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     httplog.RedactHeader(resp.Header),
+			Body:       httplog.RedactBody(body, resp.Header.Get("Content-Type"), r.RedactFields...),
+		},
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, rec Request) (*http.Response, error) {
+	key, err := matchKey(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last := -1
+	for i, in := range r.cassette.Interactions {
+		if k, err := matchKey(in.Request); err != nil || k != key {
+			continue
+		}
+		last = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if last < 0 {
+		return nil, fmt.Errorf("%w in %s: %s %s %s", ErrUnmatched, r.path, rec.Method, rec.URL, rec.Body)
+	}
+	r.used[last] = true
+
+	in := r.cassette.Interactions[last].Response
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+		StatusCode:    in.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        in.Header.Clone(),
+		Body:          ioutil.NopCloser(strings.NewReader(in.Body)),
+		ContentLength: int64(len(in.Body)),
+		Request:       req,
+	}, nil
+}
+
+// redactURL returns the URL with the values of sensitive query parameters redacted
+func (r *Recorder) redactURL(u *url.URL) string {
+	c := *u
+	if c.RawQuery != "" {
+		c.RawQuery = httplog.RedactBody([]byte(c.RawQuery), "application/x-www-form-urlencoded", r.RedactFields...)
+	}
+	c.User = nil
+	return c.String()
+}
+
+// matchKey normalizes a recorded request to the parts requests are matched by
+func matchKey(req Request) (string, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return "", err
+	}
+	body := req.Body
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch {
+	case strings.HasSuffix(mediaType, "json"):
+		var v interface{}
+		if json.Unmarshal([]byte(body), &v) == nil {
+			b, _ := json.Marshal(v)
+			body = string(b)
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		if v, err := url.ParseQuery(body); err == nil {
+			body = v.Encode()
+		}
+	}
+	return strings.Join([]string{req.Method, u.Path, u.Query().Encode(), body}, "\n"), nil
+}
@@ -0,0 +1,100 @@
+package replay_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks"
+	"github.com/bradberger/go-memberclicks/memberclickstest"
+	"github.com/bradberger/go-memberclicks/replay"
+)
+
+var ctx = context.Background()
+
+func newAPI(baseURL string, r *replay.Recorder) *memberclicks.API {
+	a, _ := memberclicks.New("test", memberclickstest.DefaultClientID, memberclickstest.DefaultClientSecret,
+		memberclicks.WithBaseURL(baseURL), memberclicks.WithHTTPClient(r.Client()))
+	return a
+}
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "profiles.json")
+
+	srv := memberclickstest.NewServer(nil)
+	rec, err := replay.New(path, replay.ModeRecord)
+	if !assert.NoError(t, err) {
+		return
+	}
+	rec.RedactFields = []string{"[Email | Primary]"}
+	a := newAPI(srv.URL, rec)
+	assert.NoError(t, a.Auth(ctx))
+	want, err := a.Profiles(ctx, 1, 10)
+	assert.NoError(t, err)
+	_, err = a.CreateProfileSearch(ctx, map[string]string{"[Group]": "Board"})
+	assert.NoError(t, err)
+	assert.NoError(t, rec.Save())
+	srv.Close()
+
+	b, err := ioutil.ReadFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotContains(t, string(b), "member1@example.com")
+	assert.NotContains(t, string(b), memberclickstest.DefaultClientSecret)
+	assert.NotContains(t, string(b), a.Token().AccessToken)
+
+	rep, err := replay.New(path, replay.ModeReplay)
+	if !assert.NoError(t, err) {
+		return
+	}
+	a = newAPI("http://replay.invalid", rep)
+	assert.NoError(t, a.Auth(ctx))
+	got, err := a.Profiles(ctx, 1, 10)
+	if assert.NoError(t, err) {
+		assert.Equal(t, want.TotalCount, got.TotalCount)
+		assert.Equal(t, want.Profiles[0].GetID(), got.Profiles[0].GetID())
+		assert.Equal(t, "REDACTED", got.Profiles[0].Attributes()["[Email | Primary]"])
+	}
+
+	// JSON bodies match by value
+	_, err = a.CreateProfileSearch(ctx, map[string]interface{}{"[Group]": "Board"})
+	assert.NoError(t, err)
+
+	_, err = a.Profiles(ctx, 2, 10)
+	assert.True(t, errors.Is(err, replay.ErrUnmatched))
+}
+
+func TestReplayMissingCassette(t *testing.T) {
+	_, err := replay.New(filepath.Join(t.TempDir(), "missing.json"), replay.ModeReplay)
+	assert.Error(t, err)
+}
+
+func TestRecordRequestUnchanged(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	rec, _ := replay.New(filepath.Join(t.TempDir(), "echo.json"), replay.ModeRecord)
+	// Readers other than the in-memory ones have no GetBody, so their body can only be read once
+	body := ioutil.NopCloser(strings.NewReader("a=b"))
+	req, _ := http.NewRequest("POST", srv.URL, body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := rec.RoundTrip(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "a=b", string(b))
+	assert.True(t, req.Body == body, "the caller's request is not modified")
+}
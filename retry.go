@@ -0,0 +1,160 @@
+package memberclicks
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	// DefaultRetryPolicy retries idempotent requests up to four times on network
+	// errors, 429s and 502/503/504s with exponential backoff between half a second
+	// and thirty seconds.
+	DefaultRetryPolicy RetryPolicy = &Backoff{MaxAttempts: 4, MinDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second}
+
+	_ RetryPolicy = (*Backoff)(nil)
+)
+
+// RetryPolicy decides whether a failed request should be sent again
+type RetryPolicy interface {
+	// Retry is called after the given attempt of req, starting at 1, failed with err.
+	// It returns how long to wait before the next attempt, or false to give up and
+	// return err to the caller.
+	Retry(req *http.Request, err error, attempt int) (time.Duration, bool)
+}
+
+// Backoff is a RetryPolicy with exponential backoff and jitter. A Retry-After
+// header on the failed response takes precedence over the computed delay, but if it
+// asks to wait longer than MaxDelay the request isn't retried.
+type Backoff struct {
+	// MaxAttempts is the maximum number of times a request is sent, including the first
+	MaxAttempts int
+	// MinDelay is the delay before the first retry, doubled for every retry after that
+	MinDelay time.Duration
+	// MaxDelay caps the computed delay and the Retry-After delay
+	MaxDelay time.Duration
+	// NonIdempotent also retries requests like POST which are not safe to repeat
+	NonIdempotent bool
+}
+
+// Retry implements the RetryPolicy interface
+func (b *Backoff) Retry(req *http.Request, err error, attempt int) (time.Duration, bool) {
+	if attempt >= b.MaxAttempts || !isRetryable(err) {
+		return 0, false
+	}
+	if !b.NonIdempotent && !isIdempotent(req.Method) {
+		return 0, false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if d, ok := retryAfter(apiErr.Header); ok {
+			return d, b.MaxDelay <= 0 || d <= b.MaxDelay
+		}
+	}
+	d := b.MinDelay << uint(attempt-1)
+	if d <= 0 || (b.MaxDelay > 0 && d > b.MaxDelay) {
+		d = b.MaxDelay
+	}
+	// Equal jitter keeps at least half of the delay so workers spread out without hammering the server.
+	if d > 1 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)))
+	}
+	return d, true
+}
+
+// isRetryable reports whether err is a transient network or server error
+func isRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header in either delay-seconds or HTTP-date format
+func retryAfter(h http.Header) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// makeReplayable makes sure the request body can be read again for every attempt.
+// Requests created by http.NewRequest with an in-memory body already support this.
+func makeReplayable(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
+
+// rewind resets the request body before the request is sent again
+func rewind(req *http.Request) error {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	return nil
+}
+
+// sleep waits for d or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
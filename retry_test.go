@@ -0,0 +1,96 @@
+package memberclicks
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffRetry(t *testing.T) {
+	b := &Backoff{MaxAttempts: 3, MinDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	get, _ := http.NewRequest("GET", "/", nil)
+	post, _ := http.NewRequest("POST", "/", nil)
+	unavailable := &APIError{StatusCode: 503, Header: http.Header{}}
+
+	d, ok := b.Retry(get, unavailable, 1)
+	assert.True(t, ok)
+	assert.True(t, d >= 50*time.Millisecond && d <= 100*time.Millisecond)
+
+	d, ok = b.Retry(get, unavailable, 2)
+	assert.True(t, ok)
+	assert.True(t, d >= 100*time.Millisecond && d <= 200*time.Millisecond)
+
+	_, ok = b.Retry(get, unavailable, 3)
+	assert.False(t, ok)
+	_, ok = b.Retry(post, unavailable, 1)
+	assert.False(t, ok)
+	_, ok = b.Retry(get, &APIError{StatusCode: 404}, 1)
+	assert.False(t, ok)
+	_, ok = b.Retry(get, errors.New("invalid character"), 1)
+	assert.False(t, ok)
+
+	b.NonIdempotent = true
+	_, ok = b.Retry(post, unavailable, 1)
+	assert.True(t, ok)
+
+	d, ok = b.Retry(get, &APIError{StatusCode: 429, Header: http.Header{"Retry-After": {"1"}}}, 1)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, d)
+
+	// Waiting longer than MaxDelay isn't worth it, or a day long Retry-After would block for a day
+	_, ok = b.Retry(get, &APIError{StatusCode: 429, Header: http.Header{"Retry-After": {"86400"}}}, 1)
+	assert.False(t, ok)
+	_, ok = b.Retry(get, &APIError{StatusCode: 503, Header: http.Header{"Retry-After": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}}, 1)
+	assert.False(t, ok)
+}
+
+func TestRetryAfter(t *testing.T) {
+	_, ok := retryAfter(http.Header{})
+	assert.False(t, ok)
+	_, ok = retryAfter(http.Header{"Retry-After": {"soon"}})
+	assert.False(t, ok)
+	d, ok := retryAfter(http.Header{"Retry-After": {"120"}})
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, d)
+	d, ok = retryAfter(http.Header{"Retry-After": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}})
+	assert.True(t, ok)
+	assert.True(t, d > 59*time.Minute)
+}
+
+func TestDoRetry(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"body":"` + string(b) + `"}`))
+	}))
+	defer srv.Close()
+
+	a := newAPI(t)
+	a.RetryPolicy = &Backoff{MaxAttempts: 3, NonIdempotent: true}
+
+	var res map[string]string
+	req, _ := http.NewRequest("POST", srv.URL, strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	assert.NoError(t, a.Do(ctx, req, &res))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, "grant_type=client_credentials", res["body"])
+
+	atomic.StoreInt32(&calls, 0)
+	a.RetryPolicy = &Backoff{MaxAttempts: 2}
+	req, _ = http.NewRequest("GET", srv.URL, nil)
+	err := a.Do(ctx, req, &res)
+	assert.True(t, errors.Is(err, ErrServer))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,187 @@
+package memberclicks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks/internal/tracing"
+)
+
+// streamDecoder is a result which decodes the response body while it's read, instead
+// of the whole body being read into memory first
+type streamDecoder interface {
+	decode(r io.Reader) error
+	// started reports whether decoded data was handed out already, so that the
+	// request can't be sent again without handing it out twice
+	started() bool
+}
+
+// profileStream decodes a page of profiles, calling fn for every profile. The other
+// fields of the page are decoded into page.
+type profileStream struct {
+	page  *ProfileResp
+	fn    func(*Profile) error
+	calls int
+}
+
+func (s *profileStream) started() bool {
+	return s.calls > 0
+}
+
+func (s *profileStream) decode(r io.Reader) error {
+	d := json.NewDecoder(r)
+	if err := expectDelim(d, '{'); err != nil {
+		return err
+	}
+	fields := map[string]json.RawMessage{}
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := t.(string)
+		if key != "profiles" {
+			var v json.RawMessage
+			if err := d.Decode(&v); err != nil {
+				return err
+			}
+			fields[key] = v
+			continue
+		}
+		if err := s.decodeProfiles(d); err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(d, '}'); err != nil {
+		return err
+	}
+
+	// The other fields are small, so they're decoded the easy way
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, s.page)
+}
+
+func (s *profileStream) decodeProfiles(d *json.Decoder) error {
+	t, err := d.Token()
+	if err != nil || t == nil {
+		return err
+	}
+	if t != json.Delim('[') {
+		return fmt.Errorf("memberclicks: expected profiles array, got %v", t)
+	}
+	for d.More() {
+		p := new(Profile)
+		if err := d.Decode(p); err != nil {
+			return err
+		}
+		s.calls++
+		if err := s.fn(p); err != nil {
+			return &callbackError{err}
+		}
+	}
+	return expectDelim(d, ']')
+}
+
+// callbackError is an error of the caller's callback. It's no API failure, so it isn't
+// logged, retried or counted by the CircuitBreaker, and Do returns the original error.
+// It deliberately doesn't unwrap, so that a callback's own API errors aren't mistaken
+// for the response of the request.
+type callbackError struct {
+	err error
+}
+
+func (e *callbackError) Error() string {
+	return e.err.Error()
+}
+
+func isCallbackError(err error) bool {
+	var cb *callbackError
+	return errors.As(err, &cb)
+}
+
+// countingReader counts the bytes read, for the metrics of streamed responses. It keeps
+// the error of reading, other than io.EOF, to tell network errors from decoding errors.
+type countingReader struct {
+	r   io.Reader
+	n   int
+	err error
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	if err != nil && err != io.EOF {
+		c.err = err
+	}
+	return n, err
+}
+
+func expectDelim(d *json.Decoder, delim json.Delim) error {
+	t, err := d.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("memberclicks: expected %v in JSON response, got %v", delim, t)
+	}
+	return nil
+}
+
+// EachProfile calls fn for every profile of a page, or of all pages if pageNum is less
+// than 1. Unlike Profiles, the profiles are decoded one at a time while the response is
+// read, so only one profile is in memory at a time no matter how large the pages are.
+// If fn returns an error, no more profiles are decoded and the error is returned.
+func (a *API) EachProfile(ctx context.Context, pageNum, pageSize int, fn func(*Profile) error) error {
+	ctx = withOperation(ctx, "EachProfile")
+	all := pageNum < 1
+	if all {
+		pageSize, pageNum = 100, 1
+		var span trace.Span
+		ctx, span = a.startSpan(ctx, "EachProfile", tracing.PageSize.Int(pageSize))
+		defer span.End()
+	}
+	return a.eachProfile(ctx, all, pageNum, func(n int) string {
+		return fmt.Sprintf("/api/v1/profile?pageNumber=%d&pageSize=%d", n, getPageSize(pageSize))
+	}, fn)
+}
+
+// EachProfileSearch calls fn for every profile of a page of the search, or of all pages
+// if pageNum is less than 1. The profiles are decoded one at a time like with EachProfile.
+func (a *API) EachProfileSearch(ctx context.Context, searchID string, pageNum int, fn func(*Profile) error) error {
+	ctx = withOperation(ctx, "EachProfileSearch")
+	all := pageNum < 1
+	if all {
+		pageNum = 1
+		var span trace.Span
+		ctx, span = a.startSpan(ctx, "EachProfileSearch", attribute.String("memberclicks.search_id", searchID))
+		defer span.End()
+	}
+	return a.eachProfile(ctx, all, pageNum, func(n int) string {
+		return fmt.Sprintf("/api/v1/profile?searchId=%s&pageSize=100&pageNumber=%d", searchID, n)
+	}, fn)
+}
+
+func (a *API) eachProfile(ctx context.Context, all bool, pageNum int, pageURL func(int) string, fn func(*Profile) error) error {
+	for {
+		var page ProfileResp
+		if err := a.Get(ctx, pageURL(pageNum), &profileStream{page: &page, fn: fn}); err != nil {
+			return err
+		}
+		if !all || pageNum >= page.TotalPageCount {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		pageNum++
+	}
+}
@@ -0,0 +1,116 @@
+package memberclicks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bradberger/go-memberclicks/memberclickstest"
+)
+
+func TestProfileStreamDecode(t *testing.T) {
+	var page ProfileResp
+	var ids []int64
+	s := &profileStream{page: &page, fn: func(p *Profile) error {
+		ids = append(ids, p.ID())
+		return nil
+	}}
+	body := `{"totalCount":2,"profiles":[{"[Profile ID]":1,"[Group]":["a"]},{"[Profile ID]":2}],"totalPageCount":1,"nextPageUrl":null}`
+	assert.NoError(t, s.decode(strings.NewReader(body)))
+	assert.Equal(t, []int64{1, 2}, ids)
+	assert.Equal(t, 2, page.TotalCount)
+	assert.Equal(t, 1, page.TotalPageCount)
+	assert.Empty(t, page.Profiles)
+
+	assert.NoError(t, (&profileStream{page: &page}).decode(strings.NewReader(`{"profiles":null}`)))
+	assert.Error(t, (&profileStream{page: &page}).decode(strings.NewReader(`[]`)))
+}
+
+func TestEachProfile(t *testing.T) {
+	seen := map[string]bool{}
+	err := mc.EachProfile(ctx, 0, 0, func(p *Profile) error {
+		seen[p.GetID()] = true
+		return nil
+	})
+	assert.NoError(t, err)
+	ct, err := mc.ProfilePageCt(ctx, 100)
+	assert.NoError(t, err)
+	assert.True(t, len(seen) > (ct-1)*100)
+
+	n := 0
+	assert.NoError(t, mc.EachProfile(ctx, 1, 10, func(p *Profile) error {
+		n++
+		return nil
+	}))
+	assert.Equal(t, 10, n)
+
+	stop := errors.New("stop")
+	n = 0
+	err = mc.EachProfile(ctx, 1, 10, func(p *Profile) error {
+		if n++; n == 3 {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 3, n)
+}
+
+func TestEachProfileNoRetry(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `{"totalPageCount":1,"profiles":[{"[Profile ID]":1},`)
+		w.(http.Flusher).Flush()
+		// Abort the response in the middle of the profiles
+		panic(http.ErrAbortHandler)
+	}))
+	defer srv.Close()
+
+	a := newAPI(t, WithBaseURL(srv.URL), WithAccessToken("abc"))
+	a.RetryPolicy = retryAlways{}
+
+	n := 0
+	err := a.EachProfile(ctx, 1, 10, func(p *Profile) error {
+		n++
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, n)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests), "partly handed out pages aren't sent again")
+}
+
+type retryAlways struct{}
+
+func (retryAlways) Retry(req *http.Request, err error, attempt int) (time.Duration, bool) {
+	return 0, attempt < 3
+}
+
+func TestEachProfileCallbackError(t *testing.T) {
+	srv := memberclickstest.NewServer(nil)
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	a, _ := New("test", memberclickstest.DefaultClientID, memberclickstest.DefaultClientSecret, WithBaseURL(srv.URL))
+	a.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+	a.RetryPolicy = retryAlways{}
+	assert.NoError(t, a.Auth(ctx))
+
+	// An API error of the callback's own requests isn't the response of the page
+	unauthorized := &APIError{StatusCode: http.StatusUnauthorized}
+	err := a.EachProfile(ctx, 1, 10, func(p *Profile) error { return unauthorized })
+	assert.Equal(t, unauthorized, err)
+	assert.Equal(t, 1, srv.Requests("GET /api/v1/profile"))
+	assert.Equal(t, 1, srv.Requests("POST /oauth/v1/token"), "the token isn't renewed")
+	assert.Contains(t, buf.String(), "level=INFO")
+	assert.NotContains(t, buf.String(), "level=WARN")
+}
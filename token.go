@@ -1,5 +1,27 @@
 package memberclicks
 
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Grant types which can renew a token
+const (
+	grantClientCredentials = "client_credentials"
+	grantRefreshToken      = "refresh_token"
+)
+
+var (
+	// TokenRenewBefore is how long before it expires an access token is renewed
+	TokenRenewBefore = time.Minute
+)
+
 // Token is a OAuth2 access token response from the server
 type Token struct {
 	AccessToken  string `json:"access_token"`
@@ -11,4 +33,170 @@ type Token struct {
 	ServiceID int64  `json:"serviceId"`
 	UserID    int64  `json:"userId"`
 	JTI       string `json:"jti"`
+	// Expiry is the time the token expires, calculated from ExpiresIn when the token is received.
+	// The zero value means the token does not expire.
+	Expiry time.Time `json:"expiry,omitzero"`
+}
+
+// Expired reports whether the token has expired or will within d
+func (t *Token) Expired(d time.Duration) bool {
+	return !t.Expiry.IsZero() && time.Now().Add(d).After(t.Expiry)
+}
+
+// SetToken sets the token to use on requests if no other Authorization header is set.
+// If the token has a refresh token it is renewed with it before it expires.
+func (a *API) SetToken(t *Token) *API {
+	grant := ""
+	if t.RefreshToken != "" {
+		grant = grantRefreshToken
+	}
+	a.setToken(t, grant)
+	return a
+}
+
+// Token returns a copy of the token set with Auth or SetToken, or nil if there is none
+func (a *API) Token() *Token {
+	a.RLock()
+	defer a.RUnlock()
+	if a.token == nil {
+		return nil
+	}
+	t := *a.token
+	return &t
+}
+
+func (a *API) setToken(t *Token, grant string) {
+	tok := *t
+	a.Lock()
+	a.token, a.accessToken, a.renewGrant = &tok, tok.AccessToken, grant
+	a.Unlock()
+}
+
+// noAuthKey marks requests which are sent without an Authorization header
+type noAuthKey struct{}
+
+// publicClientKey marks the token requests public clients may send, see GetTokenWithPKCE
+// and RefreshToken
+type publicClientKey struct{}
+
+// postToken sends a request to the token endpoint. Token requests always authenticate
+// the client with basic auth, never with the current access token. Public clients,
+// which have no secret, send their client ID in the form instead, but only when
+// exchanging codes with PKCE or renewing the tokens they got that way.
+func (a *API) postToken(ctx context.Context, form url.Values, t *Token) error {
+	public := a.clientSecret == "" && ctx.Value(publicClientKey{}) != nil
+	if public {
+		form.Set("client_id", a.clientID)
+		ctx = context.WithValue(ctx, noAuthKey{}, true)
+	} else if a.clientSecret == "" {
+		return fmt.Errorf("%w clientSecret", ErrMissingSetting)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", a.makeURL("/oauth/v1/token"), bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if !public {
+		req.SetBasicAuth(a.clientID, a.clientSecret)
+	}
+	if err := a.Do(ctx, req, t); err != nil {
+		return err
+	}
+	if t.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return nil
+}
+
+// authorize sets the Authorization header of req to a token from the TokenSource, the
+// current access token, or to basic auth with the client credentials if there is none.
+func (a *API) authorize(ctx context.Context, req *http.Request) error {
+	if a.TokenSource != nil {
+		t, err := a.TokenSource.Token()
+		if err != nil {
+			return err
+		}
+		t.SetAuthHeader(req)
+		return nil
+	}
+	accessToken, err := a.validAccessToken(ctx)
+	if err != nil {
+		return err
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		return nil
+	}
+	req.SetBasicAuth(a.clientID, a.clientSecret)
+	return nil
+}
+
+// validAccessToken returns the access token, renewing it first if it is about to expire.
+// Only one renewal is in flight at a time, concurrent callers wait for its result. If the
+// renewal fails, the token is used until it has actually expired.
+func (a *API) validAccessToken(ctx context.Context) (string, error) {
+	a.RLock()
+	tok, accessToken, grant := a.token, a.accessToken, a.renewGrant
+	a.RUnlock()
+	if tok == nil || grant == "" || !tok.Expired(TokenRenewBefore) {
+		return accessToken, nil
+	}
+
+	a.renewMu.Lock()
+	defer a.renewMu.Unlock()
+
+	// The renewal is not the caller's request, so don't record its response.
+	ctx = WithResponseMeta(ctx, nil)
+
+	// Another goroutine might have renewed the token while we waited.
+	a.RLock()
+	tok, accessToken, grant = a.token, a.accessToken, a.renewGrant
+	a.RUnlock()
+	if tok == nil || grant == "" || !tok.Expired(TokenRenewBefore) {
+		return accessToken, nil
+	}
+
+	var t *Token
+	var err error
+	switch grant {
+	case grantClientCredentials:
+		scope := tok.Scope
+		if scope == "" {
+			scope = a.scope()
+		}
+		t, err = a.ClientCredentials(ctx, scope)
+	case grantRefreshToken:
+		t, err = a.RefreshToken(ctx, tok.Scope, tok.RefreshToken)
+		if err == nil && t.RefreshToken == "" {
+			t.RefreshToken = tok.RefreshToken
+		}
+	}
+	a.metrics().ObserveTokenRefresh(err)
+	if err != nil {
+		if tok.Expired(0) {
+			return "", err
+		}
+		if a.Logger != nil {
+			a.Logger.WarnContext(ctx, "memberclicks: could not renew access token", slog.Time("expiry", tok.Expiry), slog.Any("error", err))
+		}
+		return accessToken, nil
+	}
+	a.setToken(t, grant)
+	return t.AccessToken, nil
+}
+
+// expireToken marks the access token req was sent with as expired, so the next request
+// renews it. It returns false if the token cannot be renewed.
+func (a *API) expireToken(req *http.Request) bool {
+	a.Lock()
+	defer a.Unlock()
+	if a.TokenSource != nil || a.token == nil || a.renewGrant == "" {
+		return false
+	}
+	if req.Header.Get("Authorization") == "Bearer "+a.accessToken {
+		tok := *a.token
+		tok.Expiry = time.Now()
+		a.token = &tok
+	}
+	return true
 }
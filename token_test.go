@@ -1 +1,129 @@
 package memberclicks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// hostTransport sends all requests to the test server at host
+type hostTransport struct {
+	host string
+}
+
+func (h *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, _ := url.Parse(h.host)
+	req.URL.Scheme, req.URL.Host = u.Scheme, u.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestTokenExpired(t *testing.T) {
+	assert.False(t, (&Token{}).Expired(time.Hour))
+	assert.False(t, (&Token{Expiry: time.Now().Add(time.Hour)}).Expired(time.Minute))
+	assert.True(t, (&Token{Expiry: time.Now().Add(time.Hour)}).Expired(2*time.Hour))
+
+	b, err := json.Marshal(&Token{AccessToken: "abc"})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "expiry", "tokens without expiry don't have one")
+}
+
+func TestTokenRenew(t *testing.T) {
+	var issued, reject int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/v1/token" {
+			_, _, ok := r.BasicAuth()
+			assert.True(t, ok)
+			n := atomic.AddInt32(&issued, 1)
+			expiresIn := 3600
+			if n == 1 {
+				expiresIn = 30
+			}
+			fmt.Fprintf(w, `{"access_token":"t%d","expires_in":%d,"scope":"read"}`, n, expiresIn)
+			return
+		}
+		if atomic.CompareAndSwapInt32(&reject, 1, 0) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"authorization":%q}`, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	a := newAPI(t, WithHTTPClient(&http.Client{Transport: &hostTransport{srv.URL}}))
+	assert.NoError(t, a.Auth(ctx))
+	assert.Equal(t, "t1", a.Token().AccessToken)
+	assert.False(t, a.Token().Expiry.IsZero())
+
+	// t1 expires within TokenRenewBefore, so concurrent requests share a single renewal.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var res map[string]string
+			assert.NoError(t, a.Get(ctx, "/api/v1/group", &res))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&issued))
+
+	// A rejected token is renewed and the request replayed.
+	atomic.StoreInt32(&reject, 1)
+	var res map[string]string
+	assert.NoError(t, a.Get(ctx, "/api/v1/group", &res))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&issued))
+	assert.Equal(t, "Bearer t3", res["authorization"])
+
+	// A static access token is never renewed.
+	a.SetAccessToken("static")
+	assert.NoError(t, a.Get(ctx, "/api/v1/group", &res))
+	assert.Equal(t, "Bearer static", res["authorization"])
+	assert.Nil(t, a.Token())
+}
+
+func TestTokenRenewFailure(t *testing.T) {
+	var issued int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/v1/token" {
+			if atomic.AddInt32(&issued, 1) > 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `{"access_token":"t1","expires_in":30}`)
+			return
+		}
+		fmt.Fprintf(w, `{"authorization":%q}`, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	a := newAPI(t, WithBaseURL(srv.URL))
+	a.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+	assert.NoError(t, a.Auth(ctx))
+
+	// t1 expires within TokenRenewBefore but is still valid, so it's used while renewing fails
+	var res map[string]string
+	assert.NoError(t, a.Get(ctx, "/api/v1/group", &res))
+	assert.Equal(t, "Bearer t1", res["authorization"])
+	assert.Equal(t, int32(2), atomic.LoadInt32(&issued))
+	assert.Contains(t, buf.String(), "could not renew access token")
+	assert.Contains(t, buf.String(), "error.status=503")
+
+	// Once it has expired, the renewal error is returned
+	a.setToken(&Token{AccessToken: "t1", Expiry: time.Now().Add(-time.Second)}, grantClientCredentials)
+	var apiErr *APIError
+	if assert.True(t, errors.As(a.Get(ctx, "/api/v1/group", &res), &apiErr)) {
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	}
+}
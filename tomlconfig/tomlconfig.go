@@ -0,0 +1,15 @@
+// Package tomlconfig adds TOML config files to memberclicks.LoadConfig. Import it for
+// its side effect:
+//
+//	import _ "github.com/bradberger/go-memberclicks/tomlconfig"
+package tomlconfig
+
+import (
+	"github.com/BurntSushi/toml"
+
+	"github.com/bradberger/go-memberclicks"
+)
+
+func init() {
+	memberclicks.RegisterConfigFormat(".toml", toml.Unmarshal)
+}
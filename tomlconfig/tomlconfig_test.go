@@ -0,0 +1,30 @@
+package tomlconfig
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bradberger/go-memberclicks"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := ioutil.WriteFile(path, []byte("orgId = \"demo\"\nclientId = \"id\"\nclientSecret = \"secret\"\ntimeout = \"10s\"\nscopes = [\"read\", \"write\"]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := memberclicks.LoadConfig(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &memberclicks.Config{
+		OrgID:        "demo",
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Timeout:      memberclicks.Duration(10 * time.Second),
+		Scopes:       []string{"read", "write"},
+	}, c)
+}
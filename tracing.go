@@ -0,0 +1,23 @@
+package memberclicks
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+
+	"github.com/bradberger/go-memberclicks/internal/tracing"
+)
+
+// withOperation names the spans of the requests made with ctx after the operation,
+// for example memberclicks.Profiles
+func withOperation(ctx context.Context, operation string) context.Context {
+	return tracing.WithOperation(ctx, operation)
+}
+
+// startSpan starts the parent span of an operation which makes multiple requests,
+// like fetching all pages of profiles. The requests get a child span each.
+func (a *API) startSpan(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx = withOperation(ctx, operation)
+	attrs = append(attrs, tracing.OrgID.String(a.orgID))
+	return tracing.Start(ctx, a.TracerProvider, "memberclicks."+operation, attrs...)
+}
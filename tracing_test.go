@@ -0,0 +1,96 @@
+package memberclicks
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `{"totalPageCount":3,"pageNumber":%s,"profiles":[{"[Profile ID]":1}]}`, r.URL.Query().Get("pageNumber"))
+	}))
+	defer srv.Close()
+
+	rec := tracetest.NewSpanRecorder()
+	a := newAPI(t, WithBaseURL(srv.URL))
+	a.RetryPolicy = &Backoff{MaxAttempts: 2}
+	a.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+
+	resp, err := a.Profiles(ctx, 0, 100)
+	assert.NoError(t, err)
+	assert.Len(t, resp.Profiles, 3)
+
+	spans := rec.Ended()
+	if !assert.Len(t, spans, 4) {
+		return
+	}
+	parent := spans[3]
+	assert.Equal(t, "memberclicks.Profiles", parent.Name())
+	for i, span := range spans[:3] {
+		assert.Equal(t, "memberclicks.Profiles", span.Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+		assert.Equal(t, int64(i+1), spanAttr(span, "memberclicks.page_number").AsInt64())
+		assert.Equal(t, int64(100), spanAttr(span, "memberclicks.page_size").AsInt64())
+		assert.Equal(t, "demo", spanAttr(span, "memberclicks.org_id").AsString())
+		assert.Equal(t, int64(200), spanAttr(span, "http.response.status_code").AsInt64())
+	}
+	assert.Equal(t, int64(0), spanAttr(spans[0], "memberclicks.retry_count").AsInt64())
+	assert.Equal(t, int64(1), spanAttr(spans[1], "memberclicks.retry_count").AsInt64())
+}
+
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracingRetryCount(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/v1/token" {
+			w.Write([]byte(`{"access_token":"new","expires_in":3600}`))
+			return
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	rec := tracetest.NewSpanRecorder()
+	a := newAPI(t, WithBaseURL(srv.URL))
+	a.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	a.RetryPolicy = &Backoff{MaxAttempts: 3}
+	assert.NoError(t, a.Auth(ctx))
+
+	// The request sent again after the reauthorization isn't a retry
+	assert.NoError(t, a.Get(withOperation(ctx, "Groups"), "/api/v1/group", nil))
+	// Nothing is sent while the circuit is open
+	a.CircuitBreaker = &CircuitBreaker{state: CircuitOpen, OpenTimeout: time.Hour, openedAt: time.Now()}
+	assert.Equal(t, ErrCircuitOpen, a.Get(withOperation(ctx, "Events"), "/api/v1/event", nil))
+
+	for _, span := range rec.Ended() {
+		if span.Name() == "memberclicks.Groups" || span.Name() == "memberclicks.Events" {
+			assert.Equal(t, int64(0), spanAttr(span, "memberclicks.retry_count").AsInt64(), span.Name())
+		}
+	}
+}
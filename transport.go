@@ -0,0 +1,48 @@
+package memberclicks
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+var (
+	// DefaultTransport is the transport shared by all clients without their own Client
+	// or Transport, so that connections to the API servers are reused between clients.
+	DefaultTransport http.RoundTripper = NewTransport()
+)
+
+// NewTransport returns an HTTP transport tuned for the API servers. All requests of a
+// client go to the same host, so far more idle connections per host are kept than
+// with http.DefaultTransport, which keeps two.
+func NewTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// WithTransport sets the transport requests are sent with, instead of DefaultTransport.
+// Use NewTransport for a transport of the client's own.
+func WithTransport(t http.RoundTripper) Option {
+	return func(a *API) error {
+		a.Transport = t
+		return nil
+	}
+}
+
+func (a *API) transport() http.RoundTripper {
+	if a.Transport != nil {
+		return a.Transport
+	}
+	return DefaultTransport
+}
@@ -0,0 +1,16 @@
+// Package yamlconfig adds YAML config files to memberclicks.LoadConfig. Import it for
+// its side effect:
+//
+//	import _ "github.com/bradberger/go-memberclicks/yamlconfig"
+package yamlconfig
+
+import (
+	"gopkg.in/yaml.v3"
+
+	"github.com/bradberger/go-memberclicks"
+)
+
+func init() {
+	memberclicks.RegisterConfigFormat(".yaml", yaml.Unmarshal)
+	memberclicks.RegisterConfigFormat(".yml", yaml.Unmarshal)
+}